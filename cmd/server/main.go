@@ -1,17 +1,35 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"encoding/hex"
 	"flag"
 	"fmt"
-	"log"
+	"net"
+	"net/http"
 	"os"
 
+	"time"
+
+	"github.com/cupax/cupax/internal/agentpool"
 	"github.com/cupax/cupax/internal/analyzer"
 	"github.com/cupax/cupax/internal/api"
 	"github.com/cupax/cupax/internal/config"
 	"github.com/cupax/cupax/internal/database"
 	"github.com/cupax/cupax/internal/filter"
+	"github.com/cupax/cupax/internal/logging"
+	"github.com/cupax/cupax/internal/metrics"
 	"github.com/cupax/cupax/internal/proxmox"
+	"github.com/cupax/cupax/internal/retention"
+	"github.com/cupax/cupax/internal/rules"
+	"github.com/cupax/cupax/internal/sandbox"
+	externalsandbox "github.com/cupax/cupax/internal/sandbox/external"
+	libvirtsandbox "github.com/cupax/cupax/internal/sandbox/libvirt"
+	proxmoxsandbox "github.com/cupax/cupax/internal/sandbox/proxmox"
+	virtualboxsandbox "github.com/cupax/cupax/internal/sandbox/virtualbox"
+	"github.com/cupax/cupax/internal/storage"
+	"github.com/cupax/cupax/internal/threatintel"
+	"github.com/hashicorp/go-hclog"
 )
 
 const banner = `
@@ -29,8 +47,12 @@ func main() {
 	// Parse command line flags
 	configFile := flag.String("config", "cupax.json", "Path to configuration file")
 	genConfig := flag.Bool("gen-config", false, "Generate default configuration file and exit")
+	logFormat := flag.String("log-format", "text", "Log output format: text or json")
+	logLevel := flag.String("log-level", "info", "Log level: trace, debug, info, warn, or error")
 	flag.Parse()
 
+	logger := logging.New(*logFormat, *logLevel)
+
 	fmt.Println(banner)
 
 	// Generate config file if requested
@@ -43,16 +65,26 @@ func main() {
 			Data: config.DataConfig{
 				BaseDir:    "./data",
 				SamplesDir: "./data/samples",
+				Storage: config.StorageConfig{
+					Backend: "fs",
+				},
 			},
 			Analyzer: config.AnalyzerConfig{
-				Enabled:  false, // Set to true when agent is running
-				AgentURL: "http://localhost:9090",
-				Timeout:  300,
+				Enabled: false, // Set to true when at least one agent is running
+				Timeout: 300,
+				Workers: 4,
+				AgentPool: []agentpool.AgentSpec{
+					{Name: "default", URL: "http://localhost:9090", MaxConcurrent: 1},
+				},
+				AsyncReporting:  false, // Set to true once agents can reach CallbackBaseURL
+				CallbackBaseURL: "http://localhost:8080",
+				CallbackSecret:  "", // Or set CUPAX_AGENT_CALLBACK_SECRET instead of writing it here
 			},
 			Proxmox: config.ProxmoxConfig{
 				Enabled:         false, // Set to true to enable Proxmox integration
 				Host:            "https://proxmox.local:8006",
 				Node:            "pve",
+				Guest:           config.GuestQEMU, // or config.GuestLXC
 				VMID:            100,
 				TokenID:         "", // Use either token OR username/password
 				TokenSecret:     "",
@@ -61,11 +93,29 @@ func main() {
 				VerifySSL:       false,
 				RestoreSnapshot: true,
 				ShutdownAfter:   true,
+				SnapshotRetention: config.SnapshotRetentionConfig{
+					MaxCount:        0, // Set >0 or MaxAgeDays >0 to enable pruning
+					MaxAgeDays:      0,
+					IntervalMinutes: 60,
+				},
+			},
+			Sandbox: config.SandboxConfig{
+				Backend: "proxmox", // or "libvirt", "virtualbox", "external"
+			},
+			Retention: config.RetentionConfig{
+				Enabled:          false, // Set to true to archive/purge old analyses automatically
+				ArchiveDir:       "./data/archive",
+				ArchiveAfterDays: 90,
+				PurgeAfterDays:   365,
+			},
+			Metrics: config.MetricsConfig{
+				Enabled:   false, // Set to true to expose /metrics
+				Namespace: "cupax",
 			},
 		}
 
 		if err := cfg.Save(*configFile); err != nil {
-			log.Fatalf("Failed to generate config file: %v", err)
+			fatal(logger, "Failed to generate config file", err)
 		}
 
 		fmt.Printf("Generated config file: %s\n", *configFile)
@@ -75,23 +125,24 @@ func main() {
 	// Load configuration
 	cfg, err := config.Load(*configFile)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal(logger, "Failed to load configuration", err)
 	}
 
-	log.Printf("Using configuration file: %s", *configFile)
-	log.Printf("Data directory: %s", cfg.Data.BaseDir)
-	log.Printf("Samples directory: %s", cfg.Data.SamplesDir)
-	log.Printf("Agent enabled: %v", cfg.Analyzer.Enabled)
+	logger.Info("using configuration file", "path", *configFile)
+	logger.Info("data directories", "base_dir", cfg.Data.BaseDir, "samples_dir", cfg.Data.SamplesDir)
+	logger.Info("agent enabled", "enabled", cfg.Analyzer.Enabled)
 	if cfg.Analyzer.Enabled {
-		log.Printf("Agent URL: %s", cfg.Analyzer.AgentURL)
+		for _, spec := range cfg.Analyzer.AgentPool {
+			logger.Info("agent pool member", "name", spec.Name, "url", spec.URL, "os", spec.OS, "arch", spec.Arch, "weight", spec.Weight, "max_concurrent", spec.MaxConcurrent)
+		}
 	}
 
 	// Ensure data directories exist
 	if err := os.MkdirAll(cfg.Data.BaseDir, 0755); err != nil {
-		log.Fatalf("Failed to create data directory: %v", err)
+		fatal(logger, "Failed to create data directory", err)
 	}
 	if err := os.MkdirAll(cfg.Data.SamplesDir, 0755); err != nil {
-		log.Fatalf("Failed to create samples directory: %v", err)
+		fatal(logger, "Failed to create samples directory", err)
 	}
 
 	// Initialize database
@@ -101,74 +152,281 @@ func main() {
 
 	db, err := database.New(dbConfig)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal(logger, "Failed to connect to database", err)
 	}
 	defer db.Close()
 
-	log.Println("Database connected successfully")
+	logger.Info("database connected successfully")
 
 	// Initialize schema
 	if err := db.InitSchema(); err != nil {
-		log.Fatalf("Failed to initialize database schema: %v", err)
+		fatal(logger, "Failed to initialize database schema", err)
 	}
-	log.Println("Database schema initialized")
+	logger.Info("database schema initialized")
+
+	// Create the metrics registry. Collectors are always registered so
+	// instrumentation stays cheap and unconditional; cfg.Metrics.Enabled
+	// only gates whether /metrics is actually exposed.
+	m := metrics.New(cfg.Metrics.Namespace, cfg.Metrics.Subsystem)
 
 	// Create repository
-	repo := database.NewRepository(db)
+	repo := database.NewRepository(db, logger.Named("database"), m)
 
 	// Seed default whitelists
 	if err := repo.SeedDefaultWhitelists(); err != nil {
-		log.Printf("Warning: Failed to seed default whitelists: %v", err)
+		logger.Warn("failed to seed default whitelists", "error", err)
 	}
 
 	// Create whitelist filter
-	whitelistFilter := filter.NewWhitelistFilter(repo)
+	whitelistFilter := filter.NewWhitelistFilter(repo, m)
 	if err := whitelistFilter.LoadWhitelists(); err != nil {
-		log.Printf("Warning: Failed to load whitelists: %v", err)
+		logger.Warn("failed to load whitelists", "error", err)
 	}
 
-	// Create Proxmox client if enabled
-	var proxmoxClient *proxmox.Client
-	if cfg.Proxmox.Enabled {
-		proxmoxClient = proxmox.NewClient(cfg.Proxmox)
-		log.Printf("Proxmox integration enabled for VM %d on node %s", cfg.Proxmox.VMID, cfg.Proxmox.Node)
-		log.Printf("Proxmox features: RestoreSnapshot=%v, ShutdownAfter=%v",
-			cfg.Proxmox.RestoreSnapshot, cfg.Proxmox.ShutdownAfter)
+	// Start the threat-intel feed poller (no-op if no feeds are registered)
+	feedPoller := threatintel.NewPoller(repo, time.Hour)
+	go feedPoller.Run(make(chan struct{}))
+
+	// Start the retention worker if archiving is enabled
+	if cfg.Retention.Enabled {
+		retentionWorker := retention.NewWorker(
+			repo,
+			cfg.Retention.ArchiveDir,
+			time.Duration(cfg.Retention.ArchiveAfterDays)*24*time.Hour,
+			time.Duration(cfg.Retention.PurgeAfterDays)*24*time.Hour,
+			time.Hour,
+		)
+		logger.Info("retention worker enabled",
+			"archive_after_days", cfg.Retention.ArchiveAfterDays,
+			"purge_after_days", cfg.Retention.PurgeAfterDays,
+			"archive_dir", cfg.Retention.ArchiveDir)
+		go retentionWorker.Run(make(chan struct{}))
 	}
 
-	// Create analyzer with whitelist filter and proxmox client
-	anlz := analyzer.New(analyzer.Config{
-		AgentURL:     cfg.Analyzer.AgentURL,
-		Timeout:      cfg.Analyzer.Timeout,
-		AgentEnabled: cfg.Analyzer.Enabled,
-	}, whitelistFilter, proxmoxClient)
+	// Create the sample store (local disk by default, S3/MinIO if configured)
+	var sampleStore storage.SampleStore
+	switch cfg.Data.Storage.Backend {
+	case "s3":
+		s3Store, err := storage.NewS3Store(storage.S3Config{
+			Endpoint:  cfg.Data.Storage.Endpoint,
+			Bucket:    cfg.Data.Storage.Bucket,
+			AccessKey: cfg.Data.Storage.AccessKey,
+			SecretKey: cfg.Data.Storage.SecretKey,
+			UseSSL:    cfg.Data.Storage.UseSSL,
+			Region:    cfg.Data.Storage.Region,
+		})
+		if err != nil {
+			fatal(logger, "Failed to initialize S3 sample storage", err)
+		}
+		sampleStore = s3Store
+		logger.Info("sample storage backend", "backend", "s3", "endpoint", cfg.Data.Storage.Endpoint, "bucket", cfg.Data.Storage.Bucket)
+	default:
+		sampleStore = storage.NewFSStore(cfg.Data.SamplesDir)
+		logger.Info("sample storage backend", "backend", "fs", "dir", cfg.Data.SamplesDir)
+	}
+
+	// Create IOC matcher for blacklist scoring
+	iocMatcher := filter.NewIOCMatcher(repo)
+	if err := iocMatcher.LoadBlacklists(); err != nil {
+		logger.Warn("failed to load blacklists", "error", err)
+	}
+
+	// Load the rule pack for post-analysis Score/Verdict/ATT&CK scoring,
+	// falling back to the built-in defaults if none is configured.
+	rulePack, err := rules.LoadRulePack(cfg.Analyzer.RulePackPath)
+	if err != nil {
+		fatal(logger, "Failed to load rule pack", err)
+	}
+	rulesEngine, err := rules.NewEngine(rulePack)
+	if err != nil {
+		fatal(logger, "Failed to compile rule pack", err)
+	}
+	logger.Info("rule pack loaded", "rules", len(rulePack.Rules))
+
+	// Create the sandbox backend that controls the guest's VM lifecycle
+	// around each analysis - which one depends on cfg.Sandbox.Backend, so
+	// users on non-Proxmox infra aren't forced to stub out VM control.
+	var sandboxBackend sandbox.Backend
+	switch cfg.Sandbox.Backend {
+	case "", "proxmox":
+		if cfg.Proxmox.Enabled {
+			proxmoxClient := proxmox.NewClient(cfg.Proxmox)
+			defer proxmoxClient.Close()
+			sandboxBackend = proxmoxsandbox.New(proxmoxClient)
+			logger.Info("proxmox integration enabled", "vmid", cfg.Proxmox.VMID, "node", cfg.Proxmox.Node)
+			logger.Info("proxmox features", "restore_snapshot", cfg.Proxmox.RestoreSnapshot, "shutdown_after", cfg.Proxmox.ShutdownAfter)
+
+			sr := cfg.Proxmox.SnapshotRetention
+			if sr.MaxCount > 0 || sr.MaxAgeDays > 0 {
+				policy := proxmox.SnapshotRetentionPolicy{
+					MaxCount:  sr.MaxCount,
+					MaxAge:    time.Duration(sr.MaxAgeDays) * 24 * time.Hour,
+					KeepNames: sr.KeepNames,
+				}
+				retentionWorker := proxmox.NewRetentionWorker(proxmoxClient, policy, time.Duration(sr.IntervalMinutes)*time.Minute)
+				logger.Info("proxmox snapshot retention enabled", "max_count", sr.MaxCount, "max_age_days", sr.MaxAgeDays)
+				go retentionWorker.Run(make(chan struct{}))
+			}
+		}
+	case "libvirt":
+		sandboxBackend = libvirtsandbox.New(libvirtsandbox.Config{
+			URI:        cfg.Sandbox.Libvirt.URI,
+			DomainName: cfg.Sandbox.Libvirt.DomainName,
+		})
+		logger.Info("libvirt sandbox backend enabled", "domain", cfg.Sandbox.Libvirt.DomainName)
+	case "virtualbox":
+		sandboxBackend = virtualboxsandbox.New(virtualboxsandbox.Config{
+			VMName:         cfg.Sandbox.VirtualBox.VMName,
+			VBoxManagePath: cfg.Sandbox.VirtualBox.VBoxManagePath,
+		})
+		logger.Info("virtualbox sandbox backend enabled", "vm_name", cfg.Sandbox.VirtualBox.VMName)
+	case "external":
+		sandboxBackend = externalsandbox.New()
+		logger.Info("external sandbox backend enabled - cupax will not control any VM lifecycle")
+	default:
+		fatal(logger, fmt.Sprintf("Unknown sandbox.backend %q (expected proxmox, libvirt, virtualbox, or external)", cfg.Sandbox.Backend), nil)
+	}
+
+	// Create the sandbox agent pool and start its health-check ticker
+	pool := agentpool.NewPool(cfg.Analyzer.AgentPool, cfg.Analyzer.Affinity, time.Minute, m)
+	go pool.Run(make(chan struct{}))
+
+	// Create analyzer with whitelist filter, sandbox backend and agent pool
+	anlz, err := analyzer.New(analyzer.Config{
+		Timeout:                 cfg.Analyzer.Timeout,
+		AgentEnabled:            cfg.Analyzer.Enabled,
+		AsyncReporting:          cfg.Analyzer.AsyncReporting,
+		CallbackBaseURL:         cfg.Analyzer.CallbackBaseURL,
+		MaxConcurrentDispatches: cfg.Analyzer.MaxConcurrentDispatches,
+		TLS: analyzer.TLSConfig{
+			CABundle:   cfg.Analyzer.AgentTLSCABundle,
+			ClientCert: cfg.Analyzer.AgentTLSClientCert,
+			ClientKey:  cfg.Analyzer.AgentTLSClientKey,
+		},
+	}, whitelistFilter, iocMatcher, rulesEngine, sandboxBackend, sampleStore, pool, m, logger.Named("analyzer"))
+	if err != nil {
+		fatal(logger, "Failed to create analyzer", err)
+	}
 
 	if cfg.Analyzer.Enabled {
-		log.Println("Agent enabled - will send samples to remote agent")
+		logger.Info("agent enabled - will send samples to remote agent pool")
 
-		// Check agent health
-		if err := anlz.CheckAgentHealth(); err != nil {
-			log.Printf("WARNING: Agent health check failed: %v", err)
-			log.Printf("Uploads will fail until agent is reachable at: %s", cfg.Analyzer.AgentURL)
+		// Check agent health up front so startup logs show which agents,
+		// if any, are reachable before the first upload arrives.
+		pool.CheckHealth()
+		healthy := 0
+		for _, status := range pool.Statuses() {
+			if status.Healthy {
+				healthy++
+			}
+		}
+		if healthy == 0 {
+			logger.Warn("no agent in the pool passed its health check")
 		} else {
-			log.Println("Agent health check passed")
+			logger.Info("agent pool health check passed", "healthy", healthy, "total", len(cfg.Analyzer.AgentPool))
 		}
 	} else {
-		log.Println("Agent disabled - will create stub reports (set analyzer.enabled=true in config)")
+		logger.Info("agent disabled - will create stub reports (set analyzer.enabled=true in config)")
+	}
+
+	// Decode the whitelist-import trust store once at startup.
+	trustedKeys := make(map[string]ed25519.PublicKey, len(cfg.Whitelists.TrustedKeys))
+	for keyID, hexKey := range cfg.Whitelists.TrustedKeys {
+		raw, err := hex.DecodeString(hexKey)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			fatal(logger, fmt.Sprintf("Invalid whitelist trusted key %q: must be a %d-byte hex-encoded ed25519 public key", keyID, ed25519.PublicKeySize), nil)
+		}
+		trustedKeys[keyID] = ed25519.PublicKey(raw)
 	}
 
-	// Create handler
-	handler := api.NewHandler(repo, anlz, cfg.Data.SamplesDir)
+	// The agent callback secret is only ever taken from config or the
+	// environment, never a CLI flag, so it can't leak into `ps`/shell
+	// history; the environment variable wins if both are set.
+	callbackSecret := cfg.Analyzer.CallbackSecret
+	if v := os.Getenv("CUPAX_AGENT_CALLBACK_SECRET"); v != "" {
+		callbackSecret = v
+	}
+	if callbackSecret == "" {
+		logger.Warn("CallbackSecret is not set; analysis callbacks are accepted unsigned")
+	}
+
+	// Create handler (starts the background analysis queue)
+	handler := api.NewHandler(repo, anlz, sampleStore, pool, cfg.Analyzer.Workers, m, trustedKeys, cfg.Whitelists.AllowUnsigned, callbackSecret, logger.Named("api"))
+
+	// Serve metrics on their own listener if a separate ListenAddr is
+	// configured; otherwise GET /metrics is wired into the main router below.
+	metricsOnMainRouter := cfg.Metrics.Enabled
+	if cfg.Metrics.Enabled && cfg.Metrics.ListenAddr != "" {
+		metricsOnMainRouter = false
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", m.Handler())
+			logger.Info("metrics server listening", "addr", cfg.Metrics.ListenAddr)
+			if err := http.ListenAndServe(cfg.Metrics.ListenAddr, mux); err != nil {
+				logger.Error("metrics server stopped", "error", err)
+			}
+		}()
+	}
 
 	// Setup routes
-	router := api.SetupRoutes(handler, cfg.Server.FrontendDir)
+	router := api.SetupRoutes(handler, cfg.Server.FrontendDir, metricsOnMainRouter)
+
+	// ListenSocket is mutually inclusive with Port: if both are set, both
+	// listeners are bound. Start the socket listener in the background and
+	// let TCP (if configured) own the main goroutine, matching how Run
+	// blocks today.
+	if cfg.Server.ListenSocket != "" {
+		go serveUnixSocket(router, cfg.Server.ListenSocket, logger)
+	}
+
+	if cfg.Server.Port == "" {
+		if cfg.Server.ListenSocket == "" {
+			fatal(logger, "Either server.port or server.listen_socket must be set", nil)
+		}
+		select {} // block forever; the socket listener above does the serving
+	}
 
 	// Start server
 	addr := fmt.Sprintf(":%s", cfg.Server.Port)
-	log.Printf("CupaX server starting on http://localhost%s", addr)
-	log.Println("Press Ctrl+C to stop")
+	logger.Info("cupax server starting", "addr", fmt.Sprintf("http://localhost%s", addr))
 
 	if err := router.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		fatal(logger, "Failed to start server", err)
+	}
+}
+
+// fatal logs msg (with err, if any) at error level and exits the process
+// with status 1. hclog.Logger has no Fatal of its own, so callers that
+// previously used log.Fatalf route through here instead.
+func fatal(logger hclog.Logger, msg string, err error) {
+	if err != nil {
+		logger.Error(msg, "error", err)
+	} else {
+		logger.Error(msg)
+	}
+	os.Exit(1)
+}
+
+// serveUnixSocket binds the API router to a Unix domain socket at path,
+// replacing any stale socket file left behind by a previous run. The socket
+// is chmod'd 0660 so it's usable by a sibling container or process in the
+// same group without being world-accessible.
+func serveUnixSocket(handler http.Handler, path string, logger hclog.Logger) {
+	if err := os.RemoveAll(path); err != nil {
+		fatal(logger, fmt.Sprintf("Failed to remove stale socket %s", path), err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		fatal(logger, fmt.Sprintf("Failed to listen on socket %s", path), err)
+	}
+
+	if err := os.Chmod(path, 0660); err != nil {
+		fatal(logger, fmt.Sprintf("Failed to chmod socket %s", path), err)
+	}
+
+	logger.Info("cupax server also listening", "unix_socket", path)
+	if err := http.Serve(listener, handler); err != nil {
+		logger.Error("unix socket server stopped", "error", err)
 	}
 }
@@ -0,0 +1,297 @@
+// Package agentpool replaces the analyzer's single AgentURL with a
+// horizontally scalable farm of sandbox agents, routed per job by a
+// Nomad-style weighted affinity scheme: hard constraints filter out agents
+// that cannot run a sample at all, then the survivors are scored by summed
+// affinity weight less their current load, and the highest score is picked.
+package agentpool
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cupax/cupax/internal/metrics"
+)
+
+// AgentSpec describes one sandbox agent in the pool.
+type AgentSpec struct {
+	Name string `json:"name"`
+	// URL is either an "http(s)://host:port" endpoint or a
+	// "unix:///path/to/agent.sock" Unix domain socket; the latter is
+	// preferred when the agent runs on the same host or a sibling
+	// container with a shared volume.
+	URL           string            `json:"url"`
+	OS            string            `json:"os"`   // e.g. "windows", "linux"; empty matches any sample
+	Arch          string            `json:"arch"` // e.g. "x64", "x86"; empty matches any sample
+	Weight        int               `json:"weight"`
+	MaxConcurrent int               `json:"max_concurrent"`
+	Labels        map[string]string `json:"labels"`
+}
+
+// AffinityRule adds Weight to an agent's score when a sample's profile
+// matches its OS/Arch/Ext, mirroring Nomad's weighted placement
+// preferences. An empty field matches any value.
+type AffinityRule struct {
+	OS     string `json:"os"`
+	Arch   string `json:"arch"`
+	Ext    string `json:"ext"`
+	Weight int    `json:"weight"`
+}
+
+// SampleProfile is what the analyzer can tell about a sample before
+// running it: hints used to pick a capable, well-suited agent.
+type SampleProfile struct {
+	OS   string // derived from the sample, e.g. PE machine type -> "windows"
+	Arch string // e.g. "x64", "x86"
+	Ext  string // original filename extension, e.g. ".doc", ".exe"
+}
+
+// matches reports whether rule applies to profile; an empty rule field is a
+// wildcard that always matches.
+func (rule AffinityRule) matches(profile SampleProfile) bool {
+	if rule.OS != "" && rule.OS != profile.OS {
+		return false
+	}
+	if rule.Arch != "" && rule.Arch != profile.Arch {
+		return false
+	}
+	if rule.Ext != "" && rule.Ext != profile.Ext {
+		return false
+	}
+	return true
+}
+
+// agent is an AgentSpec plus the pool's live view of it.
+type agent struct {
+	spec AgentSpec
+
+	mu       sync.Mutex
+	healthy  bool
+	lastSeen time.Time
+	inflight int
+}
+
+// AgentStatus is the /api/agents projection of an agent's current state.
+type AgentStatus struct {
+	Name          string    `json:"name"`
+	URL           string    `json:"url"`
+	OS            string    `json:"os"`
+	Arch          string    `json:"arch"`
+	Healthy       bool      `json:"healthy"`
+	LastSeen      time.Time `json:"last_seen"`
+	Inflight      int       `json:"inflight"`
+	MaxConcurrent int       `json:"max_concurrent"`
+}
+
+// Pool is a routable, health-checked set of sandbox agents.
+type Pool struct {
+	agents     []*agent
+	affinity   []AffinityRule
+	httpClient *http.Client
+	interval   time.Duration
+	metrics    *metrics.Metrics
+}
+
+// NewPool builds a pool from specs, applying the given affinity rules to
+// every routing decision. interval controls how often Run re-checks agent
+// health; a single pass can always be triggered with CheckHealth. m may be
+// nil, in which case health checks simply aren't recorded as metrics.
+func NewPool(specs []AgentSpec, affinity []AffinityRule, interval time.Duration, m *metrics.Metrics) *Pool {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	agents := make([]*agent, 0, len(specs))
+	for _, spec := range specs {
+		if spec.MaxConcurrent <= 0 {
+			spec.MaxConcurrent = 1
+		}
+		agents = append(agents, &agent{spec: spec})
+	}
+
+	return &Pool{
+		agents:     agents,
+		affinity:   affinity,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		interval:   interval,
+		metrics:    m,
+	}
+}
+
+// Run blocks, health-checking every agent on each tick, until stopCh is
+// closed.
+func (p *Pool) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.CheckHealth()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.CheckHealth()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// CheckHealth probes every agent once, logging failures per-agent so one
+// unreachable sandbox doesn't block the rest.
+func (p *Pool) CheckHealth() {
+	for _, a := range p.agents {
+		err := p.checkAgentHealth(a.spec)
+
+		a.mu.Lock()
+		a.healthy = err == nil
+		if err == nil {
+			a.lastSeen = time.Now()
+		}
+		a.mu.Unlock()
+
+		if p.metrics != nil {
+			p.metrics.SetAgentHealth(a.spec.Name, err == nil)
+		}
+
+		if err != nil {
+			log.Printf("agentpool: agent %s (%s) failed health check: %v", a.spec.Name, a.spec.URL, err)
+		}
+	}
+}
+
+func (p *Pool) checkAgentHealth(spec AgentSpec) error {
+	client, base := ClientFor(spec, p.httpClient)
+	resp, err := client.Get(fmt.Sprintf("%s/health", base))
+	if err != nil {
+		return fmt.Errorf("agent unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("agent returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Statuses returns a snapshot of every agent for /api/agents.
+func (p *Pool) Statuses() []AgentStatus {
+	statuses := make([]AgentStatus, 0, len(p.agents))
+	for _, a := range p.agents {
+		a.mu.Lock()
+		statuses = append(statuses, AgentStatus{
+			Name:          a.spec.Name,
+			URL:           a.spec.URL,
+			OS:            a.spec.OS,
+			Arch:          a.spec.Arch,
+			Healthy:       a.healthy,
+			LastSeen:      a.lastSeen,
+			Inflight:      a.inflight,
+			MaxConcurrent: a.spec.MaxConcurrent,
+		})
+		a.mu.Unlock()
+	}
+	return statuses
+}
+
+// Acquire picks the best agent for profile and reserves a concurrency slot
+// on it. Selection is: filter to healthy agents capable of the sample
+// (OS/Arch hard constraints), score survivors by summed affinity weight
+// minus their inflight count, and pick the max. If every capable
+// agent is saturated (inflight >= MaxConcurrent), fall back to any healthy
+// agent regardless of capability so a job still runs somewhere. The
+// returned release func must be called exactly once when the job finishes.
+func (p *Pool) Acquire(profile SampleProfile) (AgentSpec, func(), error) {
+	candidates := p.healthyCapable(profile)
+	picked := p.pickAvailable(candidates, profile)
+
+	if picked == nil {
+		// All capable agents are saturated (or none exist) - fall back to
+		// any healthy agent with room, ignoring capability matching.
+		picked = p.pickAvailable(p.healthy(), profile)
+	}
+
+	if picked == nil {
+		return AgentSpec{}, nil, fmt.Errorf("no healthy agent available")
+	}
+
+	picked.mu.Lock()
+	picked.inflight++
+	picked.mu.Unlock()
+
+	release := func() {
+		picked.mu.Lock()
+		picked.inflight--
+		picked.mu.Unlock()
+	}
+
+	return picked.spec, release, nil
+}
+
+func (p *Pool) healthy() []*agent {
+	var out []*agent
+	for _, a := range p.agents {
+		a.mu.Lock()
+		healthy := a.healthy
+		a.mu.Unlock()
+		if healthy {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// healthyCapable is healthy() filtered to agents whose declared OS/Arch
+// satisfy profile's hard constraints. An agent field left blank matches
+// any sample.
+func (p *Pool) healthyCapable(profile SampleProfile) []*agent {
+	var out []*agent
+	for _, a := range p.healthy() {
+		if a.spec.OS != "" && profile.OS != "" && a.spec.OS != profile.OS {
+			continue
+		}
+		if a.spec.Arch != "" && profile.Arch != "" && a.spec.Arch != profile.Arch {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// pickAvailable scores candidates with room for another job and returns
+// the highest-scoring one, or nil if none have room.
+func (p *Pool) pickAvailable(candidates []*agent, profile SampleProfile) *agent {
+	var best *agent
+	var bestScore int
+
+	for _, a := range candidates {
+		a.mu.Lock()
+		hasRoom := a.inflight < a.spec.MaxConcurrent
+		inflight := a.inflight
+		a.mu.Unlock()
+
+		if !hasRoom {
+			continue
+		}
+
+		score := p.score(a.spec, profile) - inflight
+		if best == nil || score > bestScore {
+			best = a
+			bestScore = score
+		}
+	}
+
+	return best
+}
+
+// score sums spec.Weight and every matching affinity rule's weight.
+func (p *Pool) score(spec AgentSpec, profile SampleProfile) int {
+	score := spec.Weight
+	for _, rule := range p.affinity {
+		if rule.matches(profile) {
+			score += rule.Weight
+		}
+	}
+	return score
+}
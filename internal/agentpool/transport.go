@@ -0,0 +1,47 @@
+package agentpool
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// unixURLPrefix marks an AgentSpec.URL as a Unix domain socket path rather
+// than an http(s) endpoint.
+const unixURLPrefix = "unix://"
+
+// unixClients caches one *http.Client per socket path so repeated calls to
+// ClientFor reuse the same Transport (and its connection pool) instead of
+// dialing fresh for every request.
+var unixClients sync.Map // socket path (string) -> *http.Client
+
+// ClientFor returns the HTTP client and base URL to use when talking to
+// spec. For a plain http(s) URL it's just (fallback, spec.URL). For a
+// "unix:///path/to/agent.sock" URL, the socket path - not a host:port - is
+// what actually selects the peer, so it returns a client whose Transport
+// dials that socket directly and a placeholder "http://unix" base URL to
+// build requests against.
+func ClientFor(spec AgentSpec, fallback *http.Client) (*http.Client, string) {
+	if !strings.HasPrefix(spec.URL, unixURLPrefix) {
+		return fallback, spec.URL
+	}
+
+	socketPath := strings.TrimPrefix(spec.URL, unixURLPrefix)
+	if cached, ok := unixClients.Load(socketPath); ok {
+		return cached.(*http.Client), "http://unix"
+	}
+
+	client := &http.Client{
+		Timeout: fallback.Timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	unixClients.Store(socketPath, client)
+	return client, "http://unix"
+}
@@ -2,57 +2,157 @@ package analyzer
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/cupax/cupax/internal/agentpool"
 	"github.com/cupax/cupax/internal/filter"
+	"github.com/cupax/cupax/internal/metrics"
 	"github.com/cupax/cupax/internal/models"
-	"github.com/cupax/cupax/internal/proxmox"
+	"github.com/cupax/cupax/internal/rules"
+	"github.com/cupax/cupax/internal/sandbox"
+	"github.com/cupax/cupax/internal/storage"
+	"github.com/hashicorp/go-hclog"
 )
 
+// presignedGetTTL bounds how long a presigned sample URL handed to the
+// agent stays valid; long enough to cover queueing plus transfer, short
+// enough that a leaked URL doesn't stay usable.
+const presignedGetTTL = 15 * time.Minute
+
 // Config holds analyzer configuration
 type Config struct {
-	AgentURL     string // Agent HTTP URL (e.g., http://agent-vm:9090)
-	Timeout      int    // Analysis timeout in seconds
-	AgentEnabled bool   // Whether to run analysis (false for testing)
+	Timeout      int  // Analysis timeout in seconds
+	AgentEnabled bool // Whether to run analysis (false for testing)
+
+	// AsyncReporting switches the agent protocol from one blocking
+	// /analyze call held open for the whole analysis window to a
+	// fire-and-forget dispatch: the agent accepts the job immediately and
+	// reports back later via POST CallbackBaseURL +
+	// /api/v1/internal/analyses/{id}/report. This frees the agent's own
+	// connection and pool slot for the rest of the analysis (the queue
+	// worker still blocks until the callback arrives or Timeout elapses),
+	// at the cost of needing the agent to be able to reach CallbackBaseURL.
+	AsyncReporting bool
+
+	// CallbackBaseURL is this server's own externally-reachable base URL,
+	// handed to the agent so it knows where to POST its report. Only used
+	// when AsyncReporting is true.
+	CallbackBaseURL string
+
+	// MaxConcurrentDispatches bounds how many /analyze dispatches are
+	// outstanding at once under AsyncReporting; 0 means unbounded.
+	MaxConcurrentDispatches int
+
+	// TLS configures mTLS to the sandbox agent pool. A zero value leaves
+	// httpClient on plain TLS verification with no client certificate.
+	TLS TLSConfig
+}
+
+// TLSConfig names the PEM files used to speak mTLS to the sandbox agent.
+// All fields are optional and independent: CABundle alone pins the agent's
+// server certificate without presenting a client one, and vice versa.
+type TLSConfig struct {
+	CABundle   string // path to a PEM bundle of CAs trusted for the agent's server certificate
+	ClientCert string // path to this server's PEM client certificate
+	ClientKey  string // path to ClientCert's PEM private key
 }
 
-// Analyzer executes malware analysis via remote agent
+// buildTLSConfig loads cfg's PEM files into a *tls.Config for the agent
+// httpClient's transport. Callers should skip calling this at all when cfg
+// is the zero value, since an empty tls.Config still changes dialing
+// behavior (e.g. disables TLS session reuse across a fresh *http.Transport).
+func buildTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load agent client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Analyzer executes malware analysis via a pool of remote sandbox agents
 type Analyzer struct {
 	config          Config
 	httpClient      *http.Client
 	whitelistFilter *filter.WhitelistFilter
-	proxmoxClient   *proxmox.Client // Optional Proxmox client for VM control
+	iocMatcher      *filter.IOCMatcher // Optional; nil disables IOC/blacklist scoring
+	rulesEngine     *rules.Engine      // Optional; nil disables rule-pack scoring/ATT&CK tagging
+	backend         sandbox.Backend    // Optional VM/hypervisor control around each analysis; nil disables it
+	store           storage.SampleStore
+	pool            *agentpool.Pool
+	metrics         *metrics.Metrics
+	jobQueue        *JobQueue // Correlates async dispatches with their callback; see Config.AsyncReporting
+	logger          hclog.Logger
 }
 
-// New creates a new analyzer instance
-func New(cfg Config, whitelistFilter *filter.WhitelistFilter, proxmoxClient *proxmox.Client) *Analyzer {
+// New creates a new analyzer instance. backend may be nil, in which case
+// analyses run with no VM lifecycle control at all (e.g. a bare hardware
+// sandbox or a pre-provisioned agent the caller manages itself). logger is
+// used as-is, not Named: callers should stamp analysis_id via With() before
+// an analysis-scoped log call, since Analyze itself is only ever given an
+// analysisID string, not a ready-made logger. Returns an error only if
+// cfg.TLS names PEM files that can't be read or parsed.
+func New(cfg Config, whitelistFilter *filter.WhitelistFilter, iocMatcher *filter.IOCMatcher, rulesEngine *rules.Engine, backend sandbox.Backend, store storage.SampleStore, pool *agentpool.Pool, m *metrics.Metrics, logger hclog.Logger) (*Analyzer, error) {
 	// Set defaults
 	if cfg.Timeout == 0 {
 		cfg.Timeout = 300 // 5 minutes default
 	}
-	if cfg.AgentURL == "" {
-		cfg.AgentURL = "http://localhost:9090"
-	}
 
 	// Create HTTP client with timeout
 	client := &http.Client{
 		Timeout: time.Duration(cfg.Timeout+60) * time.Second,
 	}
 
+	if cfg.TLS.CABundle != "" || cfg.TLS.ClientCert != "" || cfg.TLS.ClientKey != "" {
+		tlsConfig, err := buildTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure agent mTLS: %w", err)
+		}
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
 	return &Analyzer{
 		config:          cfg,
 		httpClient:      client,
 		whitelistFilter: whitelistFilter,
-		proxmoxClient:   proxmoxClient,
-	}
+		iocMatcher:      iocMatcher,
+		rulesEngine:     rulesEngine,
+		backend:         backend,
+		store:           store,
+		pool:            pool,
+		metrics:         m,
+		jobQueue:        NewJobQueue(cfg.MaxConcurrentDispatches),
+		logger:          logger,
+	}, nil
 }
 
 // AnalyzeResult holds the result of an analysis
@@ -68,8 +168,11 @@ type AgentResponse struct {
 	Error   string                 `json:"error,omitempty"`
 }
 
-// Analyze executes analysis by sending sample to remote agent
-func (a *Analyzer) Analyze(samplePath string, analysisID string, isZip bool, zipPassword string) *AnalyzeResult {
+// Analyze executes analysis by sending sample to remote agent. ctx governs
+// cancellation of the outbound request to the agent, e.g. via the analysis
+// queue's DELETE /analyses/:id/task endpoint. sampleURI is resolved through
+// the analyzer's SampleStore, not read directly off local disk.
+func (a *Analyzer) Analyze(ctx context.Context, sampleURI string, analysisID string, isZip bool, zipPassword string) *AnalyzeResult {
 	// If agent is disabled (e.g., for testing without Windows VM)
 	if !a.config.AgentEnabled {
 		return &AnalyzeResult{
@@ -93,17 +196,37 @@ func (a *Analyzer) Analyze(samplePath string, analysisID string, isZip bool, zip
 		}
 	}
 
-	// Proxmox VM control: Restore snapshot before analysis
-	if a.proxmoxClient != nil {
-		if err := a.prepareVM(); err != nil {
-			log.Printf("Warning: Failed to prepare VM: %v", err)
-			// Continue anyway - VM might already be in good state
+	logger := a.logger.With("analysis_id", analysisID)
+
+	// Sandbox backend: restore the guest to a clean state before analysis,
+	// and shut it down afterward so a detonated sample doesn't keep
+	// executing/beaconing once the analysis is marked complete.
+	if a.backend != nil {
+		if err := a.prepareVM(ctx); err != nil {
+			logger.Warn("failed to prepare VM, continuing anyway", "error", err)
 		}
+		defer func() {
+			if err := a.cleanupVM(ctx); err != nil {
+				logger.Warn("failed to clean up VM after analysis", "error", err)
+			}
+		}()
 	}
 
-	// Send sample to agent (synchronous - waits for complete analysis)
-	report, err := a.sendToAgent(samplePath, analysisID, isZip, zipPassword)
+	// Send sample to agent: either the original synchronous protocol
+	// (blocks until the agent replies with the full report), or the async
+	// one (blocks the same queue worker, but the agent's own connection is
+	// freed the moment it accepts the job - see Config.AsyncReporting).
+	var report *models.AnalysisReport
+	var err error
+	if a.config.AsyncReporting {
+		report, err = a.analyzeAsync(ctx, sampleURI, analysisID, isZip, zipPassword)
+	} else {
+		report, err = a.sendToAgent(ctx, sampleURI, analysisID, isZip, zipPassword)
+	}
 	if err != nil {
+		if a.metrics != nil {
+			a.metrics.IncAnalysesTotal("error")
+		}
 		return &AnalyzeResult{
 			Report: nil,
 			Error:  err,
@@ -115,110 +238,84 @@ func (a *Analyzer) Analyze(samplePath string, analysisID string, isZip bool, zip
 		report = a.whitelistFilter.FilterReport(report)
 	}
 
-	return &AnalyzeResult{
-		Report: report,
-		Error:  nil,
+	// Score what's left against known-bad IOCs. Must run after whitelist
+	// filtering so conflict resolution compares against what's actually
+	// still in the report.
+	if a.iocMatcher != nil {
+		report = a.iocMatcher.MatchReport(report)
 	}
-}
-
-// prepareVM prepares the VM for analysis by restoring to latest snapshot
-func (a *Analyzer) prepareVM() error {
-	log.Println("Proxmox: Preparing VM for analysis...")
 
-	// Get latest snapshot
-	latest, err := a.proxmoxClient.GetLatestSnapshot()
-	if err != nil {
-		return fmt.Errorf("failed to get latest snapshot: %w", err)
+	// Run the rule pack last so its combined Score/Verdict/Techniques
+	// fold in the IOC score above into one final verdict.
+	if a.rulesEngine != nil {
+		report = a.rulesEngine.Score(report)
 	}
 
-	log.Printf("Proxmox: Restoring to snapshot: %s (created: %s)",
-		latest.Name, time.Unix(latest.SnapTime, 0).Format(time.RFC3339))
+	if a.metrics != nil {
+		a.metrics.IncAnalysesTotal("completed")
+	}
 
-	// Rollback to latest snapshot
-	if err := a.proxmoxClient.RollbackToSnapshot(latest.Name); err != nil {
-		return fmt.Errorf("failed to rollback to snapshot: %w", err)
+	return &AnalyzeResult{
+		Report: report,
+		Error:  nil,
 	}
+}
 
-	// Wait for VM to be in running state after rollback
-	log.Println("Proxmox: Waiting for VM to start after rollback...")
-	if err := a.proxmoxClient.WaitForStatus("running", 2*time.Minute); err != nil {
-		return fmt.Errorf("VM did not reach running state: %w", err)
+// prepareVM restores the sandbox backend's guest to a clean state for
+// analysis and confirms its agent is reachable afterward.
+func (a *Analyzer) prepareVM(ctx context.Context) error {
+	start := time.Now()
+	err := a.rollbackVM(ctx)
+	if a.metrics != nil {
+		a.metrics.ObserveVMRollback(time.Since(start))
+		if err != nil {
+			a.metrics.IncVMRollbackFailure()
+		}
 	}
+	return err
+}
 
-	// Give agent time to start
-	log.Println("Proxmox: Waiting for agent to be ready...")
-	time.Sleep(30 * time.Second)
+// rollbackVM is prepareVM's actual work, split out so prepareVM can time
+// and count failures across both steps without an early return skipping
+// the metric.
+func (a *Analyzer) rollbackVM(ctx context.Context) error {
+	if err := a.backend.Prepare(ctx); err != nil {
+		return err
+	}
 
-	// Check agent health
 	if err := a.CheckAgentHealth(); err != nil {
 		return fmt.Errorf("agent not ready after VM restore: %w", err)
 	}
 
-	log.Println("Proxmox: VM prepared successfully")
 	return nil
 }
 
-// cleanupVM shuts down the VM after analysis
-func (a *Analyzer) cleanupVM() error {
-	log.Println("Proxmox: Shutting down VM after analysis...")
-
-	// Try graceful shutdown first
-	if err := a.proxmoxClient.ShutdownVM(); err != nil {
-		log.Printf("Proxmox: Graceful shutdown failed: %v", err)
-		log.Println("Proxmox: Forcing VM stop...")
-
-		// Force stop if graceful fails
-		if err := a.proxmoxClient.StopVM(); err != nil {
-			return fmt.Errorf("failed to stop VM: %w", err)
+// cleanupVM shuts the sandbox backend's guest down after analysis
+func (a *Analyzer) cleanupVM(ctx context.Context) error {
+	start := time.Now()
+	err := a.backend.Cleanup(ctx)
+	if a.metrics != nil {
+		a.metrics.ObserveVMCleanup(time.Since(start))
+		if err != nil {
+			a.metrics.IncVMCleanupFailure()
 		}
 	}
-
-	// Wait for VM to be stopped
-	log.Println("Proxmox: Waiting for VM to stop...")
-	if err := a.proxmoxClient.WaitForStatus("stopped", 2*time.Minute); err != nil {
-		return fmt.Errorf("VM did not stop: %w", err)
-	}
-
-	log.Println("Proxmox: VM shutdown successfully")
-	return nil
+	return err
 }
 
-// sendToAgentAsync sends sample to agent (returns immediately)
-func (a *Analyzer) sendToAgentAsync(samplePath string, analysisID string, isZip bool, zipPassword string) error {
-	// Open sample file
-	file, err := os.ReadFile(samplePath)
-	if err != nil {
-		return fmt.Errorf("failed to read sample file: %w", err)
-	}
-
-	// Create multipart form
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	// Extract filename from samplePath to preserve extension
-	filename := filepath.Base(samplePath)
-
-	// Add file with proper filename (preserves extension)
-	part, err := writer.CreateFormFile("file", filename)
-	if err != nil {
-		return fmt.Errorf("failed to create form file: %w", err)
-	}
-	if _, err := part.Write(file); err != nil {
-		return fmt.Errorf("failed to write file to form: %w", err)
-	}
-
-	// Add analysis_id
+// writeAnalyzeFields writes the form fields shared by every /analyze
+// dispatch - analysis_id, the optional zip flag/password, and extraFields
+// (e.g. callback_url for the async protocol) - after the sample itself has
+// already been written to writer.
+func writeAnalyzeFields(writer *multipart.Writer, analysisID string, isZip bool, zipPassword string, extraFields map[string]string) error {
 	if err := writer.WriteField("analysis_id", analysisID); err != nil {
 		return fmt.Errorf("failed to write analysis_id: %w", err)
 	}
 
-	// Add is_zip flag
 	if isZip {
 		if err := writer.WriteField("is_zip", "true"); err != nil {
 			return fmt.Errorf("failed to write is_zip: %w", err)
 		}
-
-		// Add password if provided
 		if zipPassword != "" {
 			if err := writer.WriteField("password", zipPassword); err != nil {
 				return fmt.Errorf("failed to write password: %w", err)
@@ -226,122 +323,120 @@ func (a *Analyzer) sendToAgentAsync(samplePath string, analysisID string, isZip
 		}
 	}
 
-	if err := writer.Close(); err != nil {
-		return fmt.Errorf("failed to close multipart writer: %w", err)
+	for field, value := range extraFields {
+		if err := writer.WriteField(field, value); err != nil {
+			return fmt.Errorf("failed to write %s: %w", field, err)
+		}
 	}
 
-	// Send request to agent (agent returns immediately)
-	url := fmt.Sprintf("%s/analyze", a.config.AgentURL)
-	req, err := http.NewRequest("POST", url, body)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
+	return nil
+}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+// buildAnalyzeForm assembles the /analyze request body shared by
+// sendToAgent and sendToAgentAsync: the sample itself (preferring a
+// presigned URL over proxying bytes, see presignedGetTTL), analysis_id,
+// and the optional zip flag/password. extraFields are written after those,
+// letting callers (e.g. callback_url for the async protocol) tack on
+// fields without a second copy of the sample-handling logic.
+//
+// When a presigned URL is available the whole body is tiny (just form
+// fields) and is returned pre-buffered. Otherwise the sample's bytes are
+// streamed straight from the SampleStore to the request instead of being
+// buffered in memory: a goroutine feeds an io.Pipe through the multipart
+// writer as the HTTP transport drains the other end. trailer is non-nil
+// only in the streaming case - its "X-Sample-Sha256" entry is populated
+// with a single-pass hash (via io.TeeReader) right before the pipe is
+// closed, so callers must set it as the request's Trailer to have it sent.
+func (a *Analyzer) buildAnalyzeForm(sampleURI string, analysisID string, isZip bool, zipPassword string, extraFields map[string]string) (io.Reader, string, http.Header, error) {
+	if presignedURL, err := a.store.PresignedGet(sampleURI, presignedGetTTL); err == nil {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+
+		if err := writer.WriteField("sample_url", presignedURL); err != nil {
+			return nil, "", nil, fmt.Errorf("failed to write sample_url: %w", err)
+		}
+		if err := writeAnalyzeFields(writer, analysisID, isZip, zipPassword, extraFields); err != nil {
+			return nil, "", nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, "", nil, fmt.Errorf("failed to close multipart writer: %w", err)
+		}
 
-	// Use shorter timeout since agent returns immediately
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request to agent: %w", err)
+		return body, writer.FormDataContentType(), nil, nil
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	respBody, err := io.ReadAll(resp.Body)
+	sample, err := a.store.Get(sampleURI)
 	if err != nil {
-		return fmt.Errorf("failed to read agent response: %w", err)
+		return nil, "", nil, fmt.Errorf("failed to read sample: %w", err)
 	}
 
-	// Parse response (agent just confirms receipt)
-	var agentResp struct {
-		Success bool   `json:"success"`
-		Message string `json:"message"`
-		Error   string `json:"error,omitempty"`
-	}
-	if err := json.Unmarshal(respBody, &agentResp); err != nil {
-		return fmt.Errorf("failed to parse agent response: %w", err)
-	}
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	trailer := http.Header{"X-Sample-Sha256": nil}
 
-	if !agentResp.Success {
-		return fmt.Errorf("agent rejected sample: %s", agentResp.Error)
-	}
+	go func() {
+		defer sample.Close()
 
-	log.Printf("Agent accepted sample, analysis running in background: %s", analysisID)
-	return nil
-}
+		pw.CloseWithError(func() error {
+			// Extract filename from sampleURI to preserve extension
+			filename := filepath.Base(sampleURI)
 
-// waitForAnalysisComplete waits for the agent to finish analysis and submit report
-// The agent POSTs the report to /api/v1/internal/analyses/{id}/report
-// We simply wait for the configured timeout duration
-func (a *Analyzer) waitForAnalysisComplete(analysisID string) {
-	timeout := time.Duration(a.config.Timeout) * time.Second
-	log.Printf("Waiting for agent to complete analysis (timeout: %v)...", timeout)
+			part, err := writer.CreateFormFile("file", filename)
+			if err != nil {
+				return fmt.Errorf("failed to create form file: %w", err)
+			}
 
-	// Sleep for the timeout duration
-	// The agent will submit the report during this time via HandleSubmitReport
-	time.Sleep(timeout)
+			hasher := sha256.New()
+			if _, err := io.Copy(part, io.TeeReader(sample, hasher)); err != nil {
+				return fmt.Errorf("failed to write file to form: %w", err)
+			}
+			trailer.Set("X-Sample-Sha256", hex.EncodeToString(hasher.Sum(nil)))
 
-	log.Printf("Analysis wait period completed for: %s", analysisID)
+			if err := writeAnalyzeFields(writer, analysisID, isZip, zipPassword, extraFields); err != nil {
+				return err
+			}
+			return writer.Close()
+		}())
+	}()
+
+	return pr, writer.FormDataContentType(), trailer, nil
 }
 
-// sendToAgent sends the sample to the remote agent for analysis (DEPRECATED - use sendToAgentAsync)
-func (a *Analyzer) sendToAgent(samplePath string, analysisID string, isZip bool, zipPassword string) (*models.AnalysisReport, error) {
-	// Open sample file
-	file, err := os.ReadFile(samplePath)
+// sendToAgent picks an agent from the pool for this sample and sends it
+// there for analysis, synchronously, over the picked agent's /analyze
+// endpoint.
+func (a *Analyzer) sendToAgent(ctx context.Context, sampleURI string, analysisID string, isZip bool, zipPassword string) (*models.AnalysisReport, error) {
+	spec, release, err := a.pool.Acquire(sampleProfile(sampleURI))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read sample file: %w", err)
+		return nil, fmt.Errorf("failed to select agent: %w", err)
 	}
+	defer release()
 
-	// Create multipart form
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	// Extract filename from samplePath to preserve extension
-	filename := filepath.Base(samplePath)
-
-	// Add file with proper filename (preserves extension)
-	part, err := writer.CreateFormFile("file", filename)
+	body, contentType, trailer, err := a.buildAnalyzeForm(sampleURI, analysisID, isZip, zipPassword, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create form file: %w", err)
-	}
-	if _, err := part.Write(file); err != nil {
-		return nil, fmt.Errorf("failed to write file to form: %w", err)
-	}
-
-	// Add analysis_id
-	if err := writer.WriteField("analysis_id", analysisID); err != nil {
-		return nil, fmt.Errorf("failed to write analysis_id: %w", err)
-	}
-
-	// Add is_zip flag
-	if isZip {
-		if err := writer.WriteField("is_zip", "true"); err != nil {
-			return nil, fmt.Errorf("failed to write is_zip: %w", err)
-		}
-
-		// Add password if provided
-		if zipPassword != "" {
-			if err := writer.WriteField("password", zipPassword); err != nil {
-				return nil, fmt.Errorf("failed to write password: %w", err)
-			}
-		}
+		return nil, err
 	}
 
-	if err := writer.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
-	}
-
-	// Send request to agent
-	url := fmt.Sprintf("%s/analyze", a.config.AgentURL)
-	req, err := http.NewRequest("POST", url, body)
+	// Send request to the agent the pool picked. ClientFor transparently
+	// dials a Unix domain socket instead of TCP when spec.URL uses the
+	// "unix://" scheme.
+	client, base := agentpool.ClientFor(spec, a.httpClient)
+	url := fmt.Sprintf("%s/analyze", base)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Content-Type", contentType)
+	if trailer != nil {
+		req.Trailer = trailer
+	}
 
-	resp, err := a.httpClient.Do(req)
+	agentStart := time.Now()
+	resp, err := client.Do(req)
+	if a.metrics != nil {
+		a.metrics.ObserveDuration("agent", spec.Name, time.Since(agentStart))
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request to agent: %w", err)
 	}
@@ -366,20 +461,107 @@ func (a *Analyzer) sendToAgent(samplePath string, analysisID string, isZip bool,
 	return agentResp.Report, nil
 }
 
-// CheckAgentHealth checks if the agent is reachable
-func (a *Analyzer) CheckAgentHealth() error {
-	url := fmt.Sprintf("%s/health", a.config.AgentURL)
+// analyzeAsync dispatches sampleURI to an agent via sendToAgentAsync and
+// blocks until that agent's callback POST to
+// /api/v1/internal/analyses/{id}/report delivers a result through
+// a.jobQueue, or Config.Timeout elapses.
+func (a *Analyzer) analyzeAsync(ctx context.Context, sampleURI string, analysisID string, isZip bool, zipPassword string) (*models.AnalysisReport, error) {
+	deadline := time.Duration(a.config.Timeout) * time.Second
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
+	agentResp, err := a.jobQueue.Submit(ctx, analysisID, deadline, func(dispatchCtx context.Context) error {
+		return a.sendToAgentAsync(dispatchCtx, sampleURI, analysisID, isZip, zipPassword)
+	})
 	if err != nil {
-		return fmt.Errorf("agent unreachable: %w", err)
+		return nil, err
+	}
+
+	if !agentResp.Success {
+		return nil, fmt.Errorf("agent analysis failed: %s", agentResp.Error)
+	}
+
+	return agentResp.Report, nil
+}
+
+// sendToAgentAsync picks an agent from the pool and hands it the sample
+// the same way sendToAgent does, but with an extra callback_url field
+// telling the agent where to POST its eventual report, and returns as soon
+// as the agent acknowledges the job (HTTP 202) rather than waiting for
+// analysis to finish.
+func (a *Analyzer) sendToAgentAsync(ctx context.Context, sampleURI string, analysisID string, isZip bool, zipPassword string) error {
+	spec, release, err := a.pool.Acquire(sampleProfile(sampleURI))
+	if err != nil {
+		return fmt.Errorf("failed to select agent: %w", err)
+	}
+	defer release()
+
+	callbackURL := fmt.Sprintf("%s/api/v1/internal/analyses/%s/report", a.config.CallbackBaseURL, analysisID)
+	body, contentType, trailer, err := a.buildAnalyzeForm(sampleURI, analysisID, isZip, zipPassword, map[string]string{"callback_url": callbackURL})
+	if err != nil {
+		return err
+	}
+
+	client, base := agentpool.ClientFor(spec, a.httpClient)
+	url := fmt.Sprintf("%s/analyze", base)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if trailer != nil {
+		req.Trailer = trailer
+	}
+
+	agentStart := time.Now()
+	resp, err := client.Do(req)
+	if a.metrics != nil {
+		a.metrics.ObserveDuration("agent", spec.Name, time.Since(agentStart))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send request to agent: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("agent returned status: %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusAccepted {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("agent rejected async dispatch (status %d): %s", resp.StatusCode, string(respBody))
 	}
 
 	return nil
 }
+
+// DeliverCallback resolves analysisID's in-flight analyzeAsync call with
+// the agent's callback report. It reports false if nothing was waiting -
+// e.g. the deadline already elapsed - so the callback handler can decide
+// whether to persist the late report itself instead.
+func (a *Analyzer) DeliverCallback(analysisID string, resp *AgentResponse) bool {
+	return a.jobQueue.Deliver(analysisID, resp)
+}
+
+// CheckAgentHealth runs a health check sweep across the whole pool and
+// reports an error only if every agent failed it.
+func (a *Analyzer) CheckAgentHealth() error {
+	a.pool.CheckHealth()
+
+	healthy := false
+	for _, status := range a.pool.Statuses() {
+		if status.Healthy {
+			healthy = true
+		} else if a.metrics != nil {
+			a.metrics.IncAgentHealthCheckFailure()
+		}
+	}
+	if healthy {
+		return nil
+	}
+	return fmt.Errorf("no agent in the pool is healthy")
+}
+
+// sampleProfile derives a best-effort agentpool.SampleProfile from what's
+// cheaply available before analysis: the sample's extension. OS/Arch would
+// need to come from sniffing the PE header or archive contents, which is
+// out of scope here; with OS/Arch left blank the profile places no hard
+// constraint, so every healthy agent counts as capable.
+func sampleProfile(sampleURI string) agentpool.SampleProfile {
+	return agentpool.SampleProfile{Ext: filepath.Ext(sampleURI)}
+}
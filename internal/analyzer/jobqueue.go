@@ -0,0 +1,107 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Dispatch is the work Submit hands off once a slot is free: send the job
+// to an agent and return as soon as the agent has *accepted* it, not once
+// analysis has finished. The actual result arrives later via Deliver.
+type Dispatch func(ctx context.Context) error
+
+// JobQueue decouples dispatching an analysis to an agent from waiting for
+// its result. Analyze still blocks its caller (the queue worker that owns
+// this analysis), but the agent connection itself is released as soon as
+// it accepts the job; the eventual result is correlated back to the
+// waiting caller by analysis ID when the agent's callback POST to
+// /api/v1/internal/analyses/{id}/report arrives.
+type JobQueue struct {
+	sem chan struct{}
+
+	mu      sync.Mutex
+	pending map[string]chan *AgentResponse
+}
+
+// NewJobQueue creates a JobQueue that allows at most maxConcurrent
+// dispatches in flight at once; maxConcurrent <= 0 means unbounded.
+func NewJobQueue(maxConcurrent int) *JobQueue {
+	q := &JobQueue{pending: make(map[string]chan *AgentResponse)}
+	if maxConcurrent > 0 {
+		q.sem = make(chan struct{}, maxConcurrent)
+	}
+	return q
+}
+
+// Submit acquires a dispatch slot, runs dispatch, and then waits for
+// analysisID's callback to arrive via Deliver, up to deadline or until ctx
+// is cancelled - whichever comes first. The slot is released as soon as
+// dispatch returns, so a long-running analysis doesn't hold up the next
+// submission; only the number of in-flight dispatches is bounded, not the
+// number of analyses awaiting a callback.
+func (q *JobQueue) Submit(ctx context.Context, analysisID string, deadline time.Duration, dispatch Dispatch) (*AgentResponse, error) {
+	if q.sem != nil {
+		select {
+		case q.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	ch := q.register(analysisID)
+	defer q.deregister(analysisID)
+
+	err := dispatch(ctx)
+	if q.sem != nil {
+		<-q.sem
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to dispatch to agent: %w", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-waitCtx.Done():
+		return nil, fmt.Errorf("timed out waiting for agent callback: %w", waitCtx.Err())
+	}
+}
+
+func (q *JobQueue) register(analysisID string) chan *AgentResponse {
+	ch := make(chan *AgentResponse, 1)
+	q.mu.Lock()
+	q.pending[analysisID] = ch
+	q.mu.Unlock()
+	return ch
+}
+
+func (q *JobQueue) deregister(analysisID string) {
+	q.mu.Lock()
+	delete(q.pending, analysisID)
+	q.mu.Unlock()
+}
+
+// Deliver resolves analysisID's pending job with the agent's callback
+// result. It reports false if nothing was waiting - e.g. the wait already
+// timed out - so the callback handler can tell the agent its report
+// arrived too late to be used.
+func (q *JobQueue) Deliver(analysisID string, resp *AgentResponse) bool {
+	q.mu.Lock()
+	ch, ok := q.pending[analysisID]
+	q.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	select {
+	case ch <- resp:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cupax/cupax/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HandleCreateBlacklist handles creation of a new blacklist/IOC entry
+func (h *Handler) HandleCreateBlacklist(c *gin.Context) {
+	var req models.BlacklistCreate
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if !isValidBlacklistType(req.Type) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid blacklist type"})
+		return
+	}
+
+	if req.Severity < 0 || req.Severity > 100 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Severity must be between 0 and 100"})
+		return
+	}
+
+	// Default enabled to true if not specified; req.Enabled is a *bool so
+	// an explicit "enabled": false is distinguishable from an omitted field.
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	blacklist := &models.Blacklist{
+		ID:          uuid.New(),
+		Type:        req.Type,
+		Value:       req.Value,
+		Description: req.Description,
+		Severity:    req.Severity,
+		IsRegex:     req.IsRegex,
+		IsCIDR:      req.IsCIDR,
+		Enabled:     enabled,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	if err := h.repo.CreateBlacklist(blacklist); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create blacklist entry"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, blacklist)
+}
+
+// HandleGetBlacklists retrieves all blacklist entries, optionally filtered
+// to only enabled ones
+func (h *Handler) HandleGetBlacklists(c *gin.Context) {
+	enabledOnly := c.Query("enabled") == "true"
+
+	var blacklists []models.Blacklist
+	var err error
+
+	if enabledOnly {
+		blacklists, err = h.repo.GetEnabledBlacklists()
+	} else {
+		blacklists, err = h.repo.GetAllBlacklists()
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve blacklists"})
+		return
+	}
+
+	if blacklists == nil {
+		blacklists = []models.Blacklist{}
+	}
+
+	c.JSON(http.StatusOK, blacklists)
+}
+
+// HandleGetBlacklistByID retrieves a specific blacklist entry
+func (h *Handler) HandleGetBlacklistByID(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid blacklist ID"})
+		return
+	}
+
+	blacklist, err := h.repo.GetBlacklistByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Blacklist not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, blacklist)
+}
+
+// HandleUpdateBlacklist updates a blacklist entry
+func (h *Handler) HandleUpdateBlacklist(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid blacklist ID"})
+		return
+	}
+
+	var update models.BlacklistUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.repo.UpdateBlacklist(id, &update); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to update blacklist"})
+		return
+	}
+
+	blacklist, err := h.repo.GetBlacklistByID(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Blacklist updated successfully"})
+		return
+	}
+
+	c.JSON(http.StatusOK, blacklist)
+}
+
+// HandleDeleteBlacklist deletes a blacklist entry
+func (h *Handler) HandleDeleteBlacklist(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid blacklist ID"})
+		return
+	}
+
+	if err := h.repo.DeleteBlacklist(id); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Blacklist not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Blacklist deleted successfully"})
+}
+
+func isValidBlacklistType(t models.BlacklistType) bool {
+	switch t {
+	case models.BlacklistTypeProcess,
+		models.BlacklistTypeDomain,
+		models.BlacklistTypeIP,
+		models.BlacklistTypeRegistry,
+		models.BlacklistTypeSHA256,
+		models.BlacklistTypeMutex:
+		return true
+	default:
+		return false
+	}
+}
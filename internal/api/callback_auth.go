@@ -0,0 +1,48 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// callbackSignatureMaxSkew bounds how far X-Cupax-Timestamp may drift from
+// now, in either direction, before a callback is rejected as a possible
+// replay of an old, otherwise-valid signature.
+const callbackSignatureMaxSkew = 5 * time.Minute
+
+// verifyCallbackSignature checks an agent's report callback against
+// secret. The agent signs analysisID || sha256(body) || timestamp (each
+// concatenated as raw bytes) with HMAC-SHA256 and sends the hex-encoded
+// result as X-Cupax-Signature, alongside the Unix-seconds timestamp it
+// signed as X-Cupax-Timestamp.
+func verifyCallbackSignature(secret, analysisID string, body []byte, timestampHeader, signatureHeader string) error {
+	if timestampHeader == "" || signatureHeader == "" {
+		return fmt.Errorf("missing callback signature")
+	}
+
+	ts, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid callback timestamp")
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > callbackSignatureMaxSkew || skew < -callbackSignatureMaxSkew {
+		return fmt.Errorf("callback timestamp outside allowed skew")
+	}
+
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(analysisID))
+	mac.Write(bodyHash[:])
+	mac.Write([]byte(timestampHeader))
+	expected := mac.Sum(nil)
+
+	got, err := hex.DecodeString(signatureHeader)
+	if err != nil || !hmac.Equal(got, expected) {
+		return fmt.Errorf("invalid callback signature")
+	}
+
+	return nil
+}
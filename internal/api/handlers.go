@@ -1,35 +1,148 @@
 package api
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/cupax/cupax/internal/agentpool"
 	"github.com/cupax/cupax/internal/analyzer"
 	"github.com/cupax/cupax/internal/database"
+	"github.com/cupax/cupax/internal/metrics"
 	"github.com/cupax/cupax/internal/models"
+	"github.com/cupax/cupax/internal/queue"
+	"github.com/cupax/cupax/internal/storage"
 	"github.com/cupax/cupax/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/hashicorp/go-hclog"
 )
 
 // Handler contains all API handlers
 type Handler struct {
-	repo       *database.Repository
-	analyzer   *analyzer.Analyzer
-	samplesDir string
+	repo     *database.Repository
+	analyzer *analyzer.Analyzer
+	store    storage.SampleStore
+	pool     *agentpool.Pool
+	queue    *queue.Queue
+	metrics  *metrics.Metrics
+	logger   hclog.Logger
+
+	// trustedKeys/allowUnsigned back GET/POST /api/v1/whitelists/{export,import};
+	// see config.WhitelistsConfig.
+	trustedKeys   map[string]ed25519.PublicKey
+	allowUnsigned bool
+
+	// callbackSecret verifies the X-Cupax-Signature on POST
+	// /api/v1/internal/analyses/{id}/report; see config.AnalyzerConfig's
+	// CallbackSecret. Empty disables verification.
+	callbackSecret string
 }
 
-// NewHandler creates a new handler instance
-func NewHandler(repo *database.Repository, anlz *analyzer.Analyzer, samplesDir string) *Handler {
-	return &Handler{
-		repo:       repo,
-		analyzer:   anlz,
-		samplesDir: samplesDir,
+// NewHandler creates a new handler instance and starts its background
+// analysis queue with the given number of workers. logger is stamped onto
+// every log line this handler and its queue emit; callers typically pass
+// it already Named("api").
+func NewHandler(repo *database.Repository, anlz *analyzer.Analyzer, store storage.SampleStore, pool *agentpool.Pool, workers int, m *metrics.Metrics, trustedKeys map[string]ed25519.PublicKey, allowUnsigned bool, callbackSecret string, logger hclog.Logger) *Handler {
+	h := &Handler{
+		repo:           repo,
+		analyzer:       anlz,
+		store:          store,
+		pool:           pool,
+		metrics:        m,
+		logger:         logger,
+		trustedKeys:    trustedKeys,
+		allowUnsigned:  allowUnsigned,
+		callbackSecret: callbackSecret,
 	}
+	h.queue = queue.New(workers, h.processAnalyzeTask, logger.Named("queue"), m)
+	return h
+}
+
+// HandleGetAgents reports each sandbox agent's capability profile, health,
+// and current load, so operators can see whether the pool is keeping up or
+// needs more capacity.
+func (h *Handler) HandleGetAgents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"agents": h.pool.Statuses()})
+}
+
+// HandleMetrics serves the Prometheus exposition format for this handler's
+// registry. Only wired into the router when metrics are enabled.
+func (h *Handler) HandleMetrics(c *gin.Context) {
+	h.metrics.Handler().ServeHTTP(c.Writer, c.Request)
+}
+
+// processAnalyzeTask is the queue.Handler that drives AnalyzeTask execution:
+// it runs the analyzer and persists the result, success or failure, back to
+// the analyses table.
+func (h *Handler) processAnalyzeTask(ctx context.Context, task queue.AnalyzeTask) error {
+	logger := h.logger.With("analysis_id", task.AnalysisID)
+	logger.Info("starting queued analysis")
+
+	if h.metrics != nil && !task.EnqueuedAt.IsZero() {
+		h.metrics.ObserveDuration("queue", "", time.Since(task.EnqueuedAt))
+		h.metrics.AddAnalysisStatus("queued", -1)
+		h.metrics.AddAnalysisStatus("running", 1)
+	}
+
+	result := h.analyzer.Analyze(ctx, task.SampleURI, task.AnalysisID.String(), task.IsZip, task.ZipPassword)
+
+	// Persist with a context that survives Cancel, so a task cancelled
+	// mid-run still records its outcome instead of staying "running".
+	persistCtx := ctx
+	if ctx.Err() != nil {
+		persistCtx = context.Background()
+	}
+
+	if result.Error != nil {
+		logger.Error("analysis failed", "error", result.Error)
+
+		persistStart := time.Now()
+		err := h.repo.UpdateAnalysisErrorContext(persistCtx, task.AnalysisID, result.Error.Error())
+		if h.metrics != nil {
+			h.metrics.ObserveDuration("persist", "", time.Since(persistStart))
+			h.metrics.AddAnalysisStatus("running", -1)
+			h.metrics.AddAnalysisStatus("error", 1)
+		}
+		if err != nil {
+			logger.Error("failed to persist analysis error", "error", err)
+		}
+		return result.Error
+	}
+
+	persistStart := time.Now()
+	err := h.repo.UpdateAnalysisReportContext(persistCtx, task.AnalysisID, result.Report)
+	if h.metrics != nil {
+		h.metrics.ObserveDuration("persist", "", time.Since(persistStart))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to persist analysis report for %s: %w", task.AnalysisID, err)
+	}
+
+	if h.metrics != nil {
+		h.metrics.AddAnalysisStatus("running", -1)
+		h.metrics.AddAnalysisStatus("completed", 1)
+	}
+
+	logger.Info("analysis completed successfully")
+	return nil
+}
+
+// HandleHealth reports liveness plus background queue depth/worker state so
+// operators can see whether analyses are backing up.
+func (h *Handler) HandleHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"queue":  h.queue.Stats(),
+	})
 }
 
 // UploadFileResponse represents the file upload response
@@ -48,6 +161,9 @@ func (h *Handler) HandleUploadFile(c *gin.Context) {
 	// Get uploaded file
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
+		if h.metrics != nil {
+			h.metrics.IncUpload("error")
+		}
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "No file uploaded"})
 		return
 	}
@@ -64,11 +180,14 @@ func (h *Handler) HandleUploadFile(c *gin.Context) {
 		if zipPassword == "" {
 			zipPassword = "infected"
 		}
-		log.Printf("Auto-detected zip file: %s, using password: %s", header.Filename, zipPassword)
+		h.logger.Debug("auto-detected zip file", "filename", header.Filename, "zip_password", zipPassword)
 	}
 
 	// Validate file size (max 100MB)
 	if header.Size > 100*1024*1024 {
+		if h.metrics != nil {
+			h.metrics.IncUpload("error")
+		}
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "File size exceeds 100MB limit"})
 		return
 	}
@@ -76,24 +195,36 @@ func (h *Handler) HandleUploadFile(c *gin.Context) {
 	// Calculate SHA256 hash
 	hash, err := utils.CalculateSHA256FromReader(file)
 	if err != nil {
+		if h.metrics != nil {
+			h.metrics.IncUpload("error")
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to calculate file hash"})
 		return
 	}
 
 	// Reset file pointer for saving
 	if _, err := file.Seek(0, 0); err != nil {
+		if h.metrics != nil {
+			h.metrics.IncUpload("error")
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to process file"})
 		return
 	}
 
 	// Check if file already exists by hash
-	existing, err := h.repo.GetAnalysisByHash(hash)
+	existing, err := h.repo.GetAnalysisByHashContext(c.Request.Context(), hash)
 	if err != nil {
+		if h.metrics != nil {
+			h.metrics.IncUpload("error")
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
 		return
 	}
 
 	if existing != nil {
+		if h.metrics != nil {
+			h.metrics.IncUpload("duplicate")
+		}
 		c.JSON(http.StatusOK, UploadFileResponse{
 			AnalysisID: existing.ID,
 			Message:    "File already analyzed. Returning existing analysis.",
@@ -101,28 +232,16 @@ func (h *Handler) HandleUploadFile(c *gin.Context) {
 		return
 	}
 
-	// Ensure samples directory exists
-	if err := os.MkdirAll(h.samplesDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create samples directory"})
-		return
-	}
-
-	// Store sample with hash as filename, preserve original extension
+	// Store sample with hash as the key, preserve original extension
 	fileExt := filepath.Ext(header.Filename)
-	sampleFilename := hash + fileExt
-	samplePath := filepath.Join(h.samplesDir, sampleFilename)
 
-	log.Printf("Saving sample: %s (original: %s, ext: %s)", sampleFilename, header.Filename, fileExt)
+	h.logger.Debug("saving sample", "hash", hash, "ext", fileExt, "filename", header.Filename)
 
-	// Save file to disk
-	dst, err := os.Create(samplePath)
+	sampleURI, err := h.store.Put(hash, fileExt, file)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save file"})
-		return
-	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
+		if h.metrics != nil {
+			h.metrics.IncUpload("error")
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save file"})
 		return
 	}
@@ -133,63 +252,307 @@ func (h *Handler) HandleUploadFile(c *gin.Context) {
 		ID:             analysisID,
 		Filename:       header.Filename,
 		FileHashSHA256: hash,
-		Status:         models.StatusRunning,
+		SampleURI:      sampleURI,
+		Status:         models.StatusQueued,
 		SubmittedAt:    time.Now(),
 	}
 
-	if err := h.repo.CreateAnalysis(analysis); err != nil {
+	if err := h.repo.CreateAnalysisContext(c.Request.Context(), analysis); err != nil {
+		if h.metrics != nil {
+			h.metrics.IncUpload("error")
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create analysis record"})
 		return
 	}
 
-	// Execute analysis synchronously via remote agent
-	log.Printf("Starting analysis for file: %s (ID: %s, is_zip: %v)", header.Filename, analysisID, isZip)
-	result := h.analyzer.Analyze(samplePath, analysisID.String(), isZip, zipPassword)
+	h.logger.Info("queuing analysis", "analysis_id", analysisID, "filename", header.Filename, "is_zip", isZip)
+	h.queue.Enqueue(queue.AnalyzeTask{
+		AnalysisID:  analysisID,
+		SampleURI:   sampleURI,
+		IsZip:       isZip,
+		ZipPassword: zipPassword,
+	})
 
-	if result.Error != nil {
-		// Update analysis status to error
-		log.Printf("Analysis failed: %v", result.Error)
-		errMsg := result.Error.Error()
-		analysis.Status = models.StatusError
-		analysis.ErrorMessage = &errMsg
-		analysis.CompletedAt = &[]time.Time{time.Now()}[0]
-		h.repo.UpdateAnalysisStatus(analysisID, analysis.Status, analysis.ErrorMessage)
+	if h.metrics != nil {
+		h.metrics.IncUpload("queued")
+		h.metrics.AddSampleBytes(header.Size)
+		h.metrics.AddAnalysisStatus("queued", 1)
+	}
 
-		c.JSON(http.StatusOK, UploadFileResponse{
-			AnalysisID: analysisID,
-			Message:    "File uploaded but analysis failed. Check analysis details for errors.",
-		})
+	c.JSON(http.StatusAccepted, UploadFileResponse{
+		AnalysisID: analysisID,
+		Message:    "File uploaded and queued for analysis.",
+	})
+}
+
+// HandleRejudgeAnalysis re-enqueues a previously completed or errored
+// analysis using its already-stored sample, so operators can re-run it
+// against updated whitelists or a new agent version without re-uploading.
+func (h *Handler) HandleRejudgeAnalysis(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid analysis ID"})
+		return
+	}
+
+	analysis, err := h.repo.GetAnalysisByIDContext(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Analysis not found"})
 		return
 	}
 
-	// Update analysis with results
-	log.Printf("Analysis completed successfully for: %s", header.Filename)
-	if err := h.repo.UpdateAnalysisReport(analysisID, result.Report); err != nil {
-		log.Printf("Failed to save analysis report: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Analysis completed but failed to save results"})
+	if analysis.Status == models.StatusQueued || analysis.Status == models.StatusRunning {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Analysis is already queued or running"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, UploadFileResponse{
-		AnalysisID: analysisID,
-		Message:    "File uploaded and analyzed successfully.",
+	if analysis.SampleURI == "" {
+		c.JSON(http.StatusGone, ErrorResponse{Error: "Original sample is no longer available in storage"})
+		return
+	}
+	sample, err := h.store.Get(analysis.SampleURI)
+	if err != nil {
+		c.JSON(http.StatusGone, ErrorResponse{Error: "Original sample is no longer available in storage"})
+		return
+	}
+	sample.Close()
+
+	if err := h.repo.UpdateAnalysisStatusContext(c.Request.Context(), id, models.StatusQueued, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to queue rejudge"})
+		return
+	}
+
+	h.logger.Info("re-queuing analysis for rejudge", "analysis_id", id)
+	h.queue.Enqueue(queue.AnalyzeTask{
+		AnalysisID: id,
+		SampleURI:  analysis.SampleURI,
 	})
+
+	if h.metrics != nil {
+		h.metrics.AddAnalysisStatus("queued", 1)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"analysis_id": id, "message": "Analysis re-queued for rejudge"})
 }
 
-// HandleGetAnalyses returns all analyses
+// HandleCancelAnalysisTask cancels analysisID's queued or in-flight task.
+// It does not change the analysis's stored status itself: a task cancelled
+// mid-run is persisted as an error by the queue's handler once Analyze
+// returns, while a task cancelled before a worker picked it up leaves the
+// record queued.
+func (h *Handler) HandleCancelAnalysisTask(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid analysis ID"})
+		return
+	}
+
+	if !h.queue.Cancel(id) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "No queued or running task for this analysis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task cancellation requested"})
+}
+
+// AnalysisStatusResponse is the lightweight projection GET
+// /api/v1/analyses/:id/status returns: just enough for a poller to tell
+// whether an async analysis has finished, without sending the full report
+// on every poll. The lookup still goes through GetAnalysisByIDContext, so
+// report_json is decoded server-side all the same.
+type AnalysisStatusResponse struct {
+	ID          uuid.UUID             `json:"id"`
+	Status      models.AnalysisStatus `json:"status"`
+	SubmittedAt time.Time             `json:"submitted_at"`
+	CompletedAt *time.Time            `json:"completed_at,omitempty"`
+}
+
+// HandleGetAnalysisStatus reports analysisID's current status, for callers
+// polling an async analysis (see analyzer.Config.AsyncReporting) instead of
+// holding a connection open for the whole run.
+func (h *Handler) HandleGetAnalysisStatus(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid analysis ID"})
+		return
+	}
+
+	analysis, err := h.repo.GetAnalysisByIDContext(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Analysis not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AnalysisStatusResponse{
+		ID:          analysis.ID,
+		Status:      analysis.Status,
+		SubmittedAt: analysis.SubmittedAt,
+		CompletedAt: analysis.CompletedAt,
+	})
+}
+
+// AnalysisCallbackRequest is the body an agent POSTs to
+// /api/v1/internal/analyses/:id/report once it finishes an analysis
+// dispatched under the async protocol (see analyzer.Config.AsyncReporting).
+type AnalysisCallbackRequest struct {
+	Success bool                   `json:"success"`
+	Report  *models.AnalysisReport `json:"report,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// HandleAnalysisCallback receives an agent's asynchronous analysis result
+// and hands it to the analyzer.JobQueue goroutine still waiting on it (see
+// Analyzer.DeliverCallback). If nothing is waiting - the per-job deadline
+// already elapsed and the queue worker gave up and persisted an error -
+// the report is persisted directly here instead, so a late callback isn't
+// simply dropped; it just skips the whitelist/IOC filtering Analyze would
+// otherwise have applied.
+//
+// If callbackSecret is set, the request must carry a valid
+// X-Cupax-Signature (see verifyCallbackSignature); this is the only thing
+// standing between this endpoint and anyone who can reach it forging a
+// report for an in-flight analysis.
+func (h *Handler) HandleAnalysisCallback(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid analysis ID"})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Failed to read callback body"})
+		return
+	}
+
+	if h.callbackSecret != "" {
+		if err := verifyCallbackSignature(h.callbackSecret, idStr, body, c.GetHeader("X-Cupax-Timestamp"), c.GetHeader("X-Cupax-Signature")); err != nil {
+			h.logger.Warn("rejected analysis callback", "analysis_id", id, "error", err)
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	var req AnalysisCallbackRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid callback payload"})
+		return
+	}
+
+	agentResp := &analyzer.AgentResponse{Success: req.Success, Report: req.Report, Error: req.Error}
+	if h.analyzer.DeliverCallback(idStr, agentResp) {
+		c.JSON(http.StatusOK, gin.H{"message": "Callback delivered"})
+		return
+	}
+
+	h.logger.Warn("analysis callback arrived after its deadline; persisting directly", "analysis_id", id)
+	if req.Success {
+		err = h.repo.UpdateAnalysisReportContext(c.Request.Context(), id, req.Report)
+	} else {
+		err = h.repo.UpdateAnalysisErrorContext(c.Request.Context(), id, req.Error)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to persist late analysis callback"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Callback received after deadline; persisted directly"})
+}
+
+// HandleGetAnalyses returns a cursor-paginated, filterable page of
+// analyses. report_json is never decoded unless include_report=true is
+// passed, so listing stays cheap regardless of how large reports get.
 func (h *Handler) HandleGetAnalyses(c *gin.Context) {
-	analyses, err := h.repo.GetAllAnalyses()
+	opts := models.ListOptions{
+		FilenameLike:  c.Query("filename"),
+		HashPrefix:    c.Query("hash"),
+		IncludeReport: c.Query("include_report") == "true",
+	}
+
+	if status := c.Query("status"); status != "" {
+		s := models.AnalysisStatus(status)
+		opts.Status = &s
+	}
+
+	if pageSize, err := strconv.Atoi(c.Query("page_size")); err == nil && pageSize > 0 {
+		opts.PageSize = pageSize
+	}
+
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		cursor, err := decodeAnalysisCursor(cursorStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid cursor"})
+			return
+		}
+		opts.After = cursor
+	}
+
+	page, err := h.repo.ListAnalyses(c.Request.Context(), opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve analyses"})
 		return
 	}
 
-	// Return empty array instead of null if no analyses
-	if analyses == nil {
-		analyses = []models.Analysis{}
+	resp := gin.H{}
+	if opts.IncludeReport {
+		resp["items"] = page.Items
+	} else {
+		// Project to AnalysisSummary so the response never carries
+		// report_json unless the caller explicitly asked for it.
+		summaries := make([]models.AnalysisSummary, len(page.Items))
+		for i, a := range page.Items {
+			summaries[i] = models.AnalysisSummary{
+				ID:             a.ID,
+				Filename:       a.Filename,
+				FileHashSHA256: a.FileHashSHA256,
+				Status:         a.Status,
+				SubmittedAt:    a.SubmittedAt,
+				CompletedAt:    a.CompletedAt,
+				ArchivedAt:     a.ArchivedAt,
+			}
+		}
+		resp["items"] = summaries
+	}
+	if page.NextCursor != nil {
+		resp["next_cursor"] = encodeAnalysisCursor(*page.NextCursor)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// encodeAnalysisCursor packs a keyset cursor into an opaque page token.
+func encodeAnalysisCursor(cursor models.AnalysisCursor) string {
+	raw := cursor.SubmittedAt.UTC().Format(time.RFC3339Nano) + "|" + cursor.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeAnalysisCursor reverses encodeAnalysisCursor.
+func decodeAnalysisCursor(encoded string) (*models.AnalysisCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor encoding: %w", err)
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cursor format")
+	}
+
+	submittedAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor timestamp: %w", err)
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor id: %w", err)
 	}
 
-	c.JSON(http.StatusOK, analyses)
+	return &models.AnalysisCursor{SubmittedAt: submittedAt, ID: id}, nil
 }
 
 // HandleGetAnalysisByID returns a specific analysis
@@ -201,7 +564,7 @@ func (h *Handler) HandleGetAnalysisByID(c *gin.Context) {
 		return
 	}
 
-	analysis, err := h.repo.GetAnalysisByID(id)
+	analysis, err := h.repo.GetAnalysisByIDContext(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Analysis not found"})
 		return
@@ -5,9 +5,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRoutes configures all API routes
-func SetupRoutes(handler *Handler, frontendDir string) *gin.Engine {
-	router := gin.Default()
+// SetupRoutes configures all API routes. metricsOnMainRouter registers
+// GET /metrics here; it should be false when metrics are disabled, or when
+// they're being served on their own listener instead (see
+// config.MetricsConfig.ListenAddr).
+func SetupRoutes(handler *Handler, frontendDir string, metricsOnMainRouter bool) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(RequestLogger(handler.logger))
 
 	// CORS configuration for development
 	config := cors.DefaultConfig()
@@ -23,20 +28,51 @@ func SetupRoutes(handler *Handler, frontendDir string) *gin.Engine {
 		v1.POST("/analyze", handler.HandleUploadFile)
 		v1.GET("/analyses", handler.HandleGetAnalyses)
 		v1.GET("/analyses/:id", handler.HandleGetAnalysisByID)
+		v1.GET("/analyses/:id/status", handler.HandleGetAnalysisStatus)
+		v1.POST("/analyses/:id/rejudge", handler.HandleRejudgeAnalysis)
+		v1.DELETE("/analyses/:id/task", handler.HandleCancelAnalysisTask)
+
+		// Internal endpoints called by sandbox agents themselves, not by
+		// operators - currently just the async analysis callback (see
+		// analyzer.Config.AsyncReporting).
+		internal := v1.Group("/internal")
+		{
+			internal.POST("/analyses/:id/report", handler.HandleAnalysisCallback)
+		}
+
+		// Agent pool endpoints
+		v1.GET("/agents", handler.HandleGetAgents)
 
 		// Whitelist endpoints
 		v1.POST("/whitelists", handler.HandleCreateWhitelist)
 		v1.GET("/whitelists", handler.HandleGetWhitelists)
+		v1.GET("/whitelists/export", handler.HandleExportWhitelists)
+		v1.POST("/whitelists/import", handler.HandleImportWhitelists)
 		v1.GET("/whitelists/:id", handler.HandleGetWhitelistByID)
 		v1.PUT("/whitelists/:id", handler.HandleUpdateWhitelist)
 		v1.DELETE("/whitelists/:id", handler.HandleDeleteWhitelist)
 		v1.POST("/whitelists/bulk", handler.HandleBulkCreateWhitelists)
+
+		// Threat-intel feed endpoints
+		v1.POST("/threat-feeds", handler.HandleCreateThreatFeed)
+		v1.GET("/threat-feeds", handler.HandleGetThreatFeeds)
+		v1.DELETE("/threat-feeds/:id", handler.HandleDeleteThreatFeed)
+
+		// Blacklist/IOC endpoints
+		v1.POST("/blacklists", handler.HandleCreateBlacklist)
+		v1.GET("/blacklists", handler.HandleGetBlacklists)
+		v1.GET("/blacklists/:id", handler.HandleGetBlacklistByID)
+		v1.PUT("/blacklists/:id", handler.HandleUpdateBlacklist)
+		v1.DELETE("/blacklists/:id", handler.HandleDeleteBlacklist)
 	}
 
 	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	router.GET("/health", handler.HandleHealth)
+
+	// Metrics endpoint (Prometheus exposition format)
+	if metricsOnMainRouter {
+		router.GET("/metrics", handler.HandleMetrics)
+	}
 
 	// Serve frontend static files (if frontend directory is provided)
 	if frontendDir != "" {
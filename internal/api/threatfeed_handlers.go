@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cupax/cupax/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HandleCreateThreatFeed registers a new STIX/MISP feed subscription
+func (h *Handler) HandleCreateThreatFeed(c *gin.Context) {
+	var req models.ThreatFeedCreate
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if req.Kind != models.ThreatFeedKindSTIX && req.Kind != models.ThreatFeedKindMISP {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid feed kind"})
+		return
+	}
+
+	feed := &models.ThreatFeed{
+		ID:        uuid.New(),
+		Name:      req.Name,
+		Kind:      req.Kind,
+		URL:       req.URL,
+		Enabled:   req.Enabled,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := h.repo.CreateThreatFeed(feed); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create threat feed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, feed)
+}
+
+// HandleGetThreatFeeds retrieves every enabled threat-intel feed
+func (h *Handler) HandleGetThreatFeeds(c *gin.Context) {
+	feeds, err := h.repo.GetEnabledThreatFeeds()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve threat feeds"})
+		return
+	}
+
+	if feeds == nil {
+		feeds = []models.ThreatFeed{}
+	}
+
+	c.JSON(http.StatusOK, feeds)
+}
+
+// HandleDeleteThreatFeed removes a feed subscription and purges the
+// whitelist entries it produced
+func (h *Handler) HandleDeleteThreatFeed(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid feed ID"})
+		return
+	}
+
+	purged, err := h.repo.DeleteWhitelistsBySource(id.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to purge feed entries"})
+		return
+	}
+
+	if err := h.repo.DeleteThreatFeed(id); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Threat feed not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Threat feed deleted successfully",
+		"entries_purged": purged,
+	})
+}
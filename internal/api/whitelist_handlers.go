@@ -1,12 +1,16 @@
 package api
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/cupax/cupax/internal/models"
+	"github.com/cupax/cupax/internal/whitelistbundle"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
 )
 
 // HandleCreateWhitelist handles creation of a new whitelist entry
@@ -22,28 +26,32 @@ func (h *Handler) HandleCreateWhitelist(c *gin.Context) {
 	if req.Type != models.WhitelistTypeProcess &&
 		req.Type != models.WhitelistTypeDomain &&
 		req.Type != models.WhitelistTypeIP &&
-		req.Type != models.WhitelistTypeRegistry {
+		req.Type != models.WhitelistTypeRegistry &&
+		req.Type != models.WhitelistTypeHash {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid whitelist type"})
 		return
 	}
 
+	// Default enabled to true if not specified; req.Enabled is a *bool so
+	// an explicit "enabled": false is distinguishable from an omitted field.
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
 	whitelist := &models.Whitelist{
 		ID:          uuid.New(),
 		Type:        req.Type,
 		Value:       req.Value,
 		Description: req.Description,
 		IsRegex:     req.IsRegex,
-		Enabled:     req.Enabled,
+		IsCIDR:      req.IsCIDR,
+		Enabled:     enabled,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
-	// Default enabled to true if not specified
-	if !req.Enabled {
-		whitelist.Enabled = true
-	}
-
-	if err := h.repo.CreateWhitelist(whitelist); err != nil {
+	if err := h.repo.CreateWhitelistContext(c.Request.Context(), whitelist); err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create whitelist entry"})
 		return
 	}
@@ -60,11 +68,11 @@ func (h *Handler) HandleGetWhitelists(c *gin.Context) {
 	var err error
 
 	if enabledOnly {
-		whitelists, err = h.repo.GetEnabledWhitelists()
+		whitelists, err = h.repo.GetEnabledWhitelistsContext(c.Request.Context())
 	} else if whitelistType != "" {
-		whitelists, err = h.repo.GetWhitelistsByType(models.WhitelistType(whitelistType))
+		whitelists, err = h.repo.GetWhitelistsByTypeContext(c.Request.Context(), models.WhitelistType(whitelistType))
 	} else {
-		whitelists, err = h.repo.GetAllWhitelists()
+		whitelists, err = h.repo.GetAllWhitelistsContext(c.Request.Context())
 	}
 
 	if err != nil {
@@ -89,7 +97,7 @@ func (h *Handler) HandleGetWhitelistByID(c *gin.Context) {
 		return
 	}
 
-	whitelist, err := h.repo.GetWhitelistByID(id)
+	whitelist, err := h.repo.GetWhitelistByIDContext(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Whitelist not found"})
 		return
@@ -113,13 +121,13 @@ func (h *Handler) HandleUpdateWhitelist(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.UpdateWhitelist(id, &update); err != nil {
+	if err := h.repo.UpdateWhitelistContext(c.Request.Context(), id, &update); err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to update whitelist"})
 		return
 	}
 
 	// Fetch updated whitelist to return
-	whitelist, err := h.repo.GetWhitelistByID(id)
+	whitelist, err := h.repo.GetWhitelistByIDContext(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"message": "Whitelist updated successfully"})
 		return
@@ -137,7 +145,7 @@ func (h *Handler) HandleDeleteWhitelist(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.DeleteWhitelist(id); err != nil {
+	if err := h.repo.DeleteWhitelistContext(c.Request.Context(), id); err != nil {
 		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Whitelist not found"})
 		return
 	}
@@ -145,6 +153,84 @@ func (h *Handler) HandleDeleteWhitelist(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Whitelist deleted successfully"})
 }
 
+// HandleExportWhitelists streams every whitelist entry as a YAML bundle,
+// grouped by type, for curating in git or rolling out to other instances.
+// The export itself is unsigned; an operator who wants POST
+// /api/v1/whitelists/import to enforce signatures signs the downloaded bundle
+// offline with their own ed25519 key before redistributing it.
+func (h *Handler) HandleExportWhitelists(c *gin.Context) {
+	entries, err := h.repo.GetAllWhitelistsContext(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve whitelists"})
+		return
+	}
+
+	bundle := whitelistbundle.FromWhitelists(entries, c.Request.Host, time.Now())
+
+	data, err := yaml.Marshal(bundle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to encode export bundle"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="whitelists.yaml"`)
+	c.Data(http.StatusOK, "application/yaml", data)
+}
+
+// HandleImportWhitelists applies a YAML whitelist bundle. mode selects how
+// it's merged into the existing table: "merge" (default) upserts by
+// (type, value), "replace" deletes every existing entry first. dry_run=true
+// validates and reports without writing anything. The bundle is parsed and
+// fully validated - signature, then structure - before any of it is
+// applied, so a bad entry partway through never leaves a partial import.
+func (h *Handler) HandleImportWhitelists(c *gin.Context) {
+	mode := c.DefaultQuery("mode", "merge")
+	if mode != "merge" && mode != "replace" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: `mode must be "merge" or "replace"`})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Failed to read import bundle"})
+		return
+	}
+
+	var bundle whitelistbundle.Bundle
+	if err := yaml.Unmarshal(data, &bundle); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid YAML bundle: " + err.Error()})
+		return
+	}
+
+	if err := bundle.Verify(h.trustedKeys); err != nil {
+		if !errors.Is(err, whitelistbundle.ErrUnsigned) || !h.allowUnsigned {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "Bundle signature rejected: " + err.Error()})
+			return
+		}
+	}
+
+	if err := bundle.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid bundle: " + err.Error()})
+		return
+	}
+
+	entries := bundle.ToWhitelists()
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"mode": mode, "dry_run": true, "entries": len(entries)})
+		return
+	}
+
+	applied, err := h.repo.ImportWhitelists(entries, mode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to import whitelists: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mode": mode, "dry_run": false, "applied": applied})
+}
+
 // HandleBulkCreateWhitelists creates multiple whitelist entries at once
 func (h *Handler) HandleBulkCreateWhitelists(c *gin.Context) {
 	var requests []models.WhitelistCreate
@@ -164,12 +250,13 @@ func (h *Handler) HandleBulkCreateWhitelists(c *gin.Context) {
 			Value:       req.Value,
 			Description: req.Description,
 			IsRegex:     req.IsRegex,
+			IsCIDR:      req.IsCIDR,
 			Enabled:     true,
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
 		}
 
-		if err := h.repo.CreateWhitelist(whitelist); err != nil {
+		if err := h.repo.CreateWhitelistContext(c.Request.Context(), whitelist); err != nil {
 			errors = append(errors, "Failed to create "+req.Value+": "+err.Error())
 		} else {
 			created = append(created, *whitelist)
@@ -4,42 +4,129 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+
+	"github.com/cupax/cupax/internal/agentpool"
 )
 
 // Config holds the application configuration
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Data     DataConfig     `json:"data"`
-	Analyzer AnalyzerConfig `json:"analyzer"`
-	Proxmox  ProxmoxConfig  `json:"proxmox"`
+	Server     ServerConfig     `json:"server"`
+	Data       DataConfig       `json:"data"`
+	Analyzer   AnalyzerConfig   `json:"analyzer"`
+	Proxmox    ProxmoxConfig    `json:"proxmox"`
+	Sandbox    SandboxConfig    `json:"sandbox"`
+	Retention  RetentionConfig  `json:"retention"`
+	Metrics    MetricsConfig    `json:"metrics"`
+	Whitelists WhitelistsConfig `json:"whitelists"`
 }
 
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
 	Port        string `json:"port"`
 	FrontendDir string `json:"frontend_dir"`
+
+	// ListenSocket, if set, additionally serves the API over a Unix domain
+	// socket at this path (mode 0660) - faster and more easily sandboxed
+	// than TCP when the caller is on the same host. It is mutually
+	// inclusive with Port, not exclusive: if both are set, both listeners
+	// are bound.
+	ListenSocket string `json:"listen_socket"`
 }
 
 // DataConfig holds data directory configuration
 type DataConfig struct {
-	BaseDir     string `json:"base_dir"`
-	SamplesDir  string `json:"samples_dir"`
-	FrontendDir string `json:"frontend_dir"`
+	BaseDir     string        `json:"base_dir"`
+	SamplesDir  string        `json:"samples_dir"`
+	FrontendDir string        `json:"frontend_dir"`
+	Storage     StorageConfig `json:"storage"`
+}
+
+// StorageConfig selects and configures the backend samples are persisted
+// to. Endpoint/Bucket/AccessKey/SecretKey/UseSSL/Region are only used when
+// Backend is "s3", mirroring the MinIO client's own connection options.
+type StorageConfig struct {
+	Backend   string `json:"backend"` // "fs" (default) or "s3"
+	Endpoint  string `json:"endpoint"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	UseSSL    bool   `json:"use_ssl"`
+	Region    string `json:"region"`
 }
 
 // AnalyzerConfig holds analyzer configuration
 type AnalyzerConfig struct {
-	Enabled  bool   `json:"enabled"`   // Enable/disable agent communication
-	AgentURL string `json:"agent_url"` // Agent HTTP URL (e.g., http://agent-vm:9090)
-	Timeout  int    `json:"timeout"`   // Analysis timeout in seconds
+	Enabled bool `json:"enabled"` // Enable/disable agent communication
+	Timeout int  `json:"timeout"` // Analysis timeout in seconds
+	Workers int  `json:"workers"` // Number of background analysis queue workers
+
+	// AgentPool is the sandbox farm jobs are routed across. A single entry
+	// reproduces the old one-agent setup; multiple entries let mixed
+	// sandboxes (different OS/arch, different capacity) share the queue.
+	AgentPool []agentpool.AgentSpec `json:"agent_pool"`
+
+	// Affinity scores AgentPool members against a sample's profile
+	// (OS/arch/extension), Nomad-style: matching rules add Weight to an
+	// agent's score so, e.g., Windows samples prefer Windows agents
+	// without being barred from Linux ones if that's all that's healthy.
+	Affinity []agentpool.AffinityRule `json:"affinity"`
+
+	// AsyncReporting switches the agent protocol from one blocking
+	// /analyze call held open for the whole analysis window to a
+	// fire-and-forget dispatch, with the agent reporting back later via
+	// POST CallbackBaseURL + /api/v1/internal/analyses/{id}/report. See
+	// analyzer.Config.AsyncReporting.
+	AsyncReporting bool `json:"async_reporting"`
+
+	// CallbackBaseURL is this server's own externally-reachable base URL,
+	// handed to agents so they know where to POST their report. Required
+	// when AsyncReporting is true.
+	CallbackBaseURL string `json:"callback_base_url"`
+
+	// MaxConcurrentDispatches bounds how many /analyze dispatches are
+	// outstanding at once under AsyncReporting; 0 means unbounded.
+	MaxConcurrentDispatches int `json:"max_concurrent_dispatches"`
+
+	// AgentTLSCABundle, AgentTLSClientCert, and AgentTLSClientKey configure
+	// mTLS to the sandbox agent pool. All optional; left blank, the agent
+	// HTTP client falls back to normal TLS verification with no client
+	// certificate. See analyzer.Config.TLS.
+	AgentTLSCABundle   string `json:"agent_tls_ca_bundle"`
+	AgentTLSClientCert string `json:"agent_tls_client_cert"`
+	AgentTLSClientKey  string `json:"agent_tls_client_key"`
+
+	// CallbackSecret is the pre-shared HMAC-SHA256 key a sandbox agent
+	// signs its report callback with (see api.Handler's analysis callback
+	// endpoint). Leaving it blank disables callback signature
+	// verification, which is only safe on a trusted network. Set it here
+	// or via the CUPAX_AGENT_CALLBACK_SECRET environment variable, never a
+	// CLI flag - a flag value is visible to anyone who can read `ps`.
+	CallbackSecret string `json:"callback_secret"`
+
+	// RulePackPath is a YAML rule pack for rules.Engine's post-analysis
+	// scoring (see internal/rules). Left blank, the built-in default pack
+	// is used.
+	RulePackPath string `json:"rule_pack_path"`
 }
 
+// GuestKind selects which Proxmox guest type Node/VMID refer to: a full
+// QEMU virtual machine or a lighter-weight LXC container. The two guest
+// types expose near-identical but not quite path-symmetric APIs (see
+// internal/proxmox's guestBasePath).
+type GuestKind string
+
+const (
+	GuestQEMU GuestKind = "qemu"
+	GuestLXC  GuestKind = "lxc"
+)
+
 // ProxmoxConfig holds Proxmox VE configuration
 type ProxmoxConfig struct {
-	Enabled        bool   `json:"enabled"`         // Enable/disable Proxmox integration
-	Host           string `json:"host"`            // Proxmox host (e.g., https://proxmox.local:8006)
-	Node           string `json:"node"`            // Proxmox node name
-	VMID           int    `json:"vmid"`            // VM ID for agent
+	Enabled        bool      `json:"enabled"`         // Enable/disable Proxmox integration
+	Host           string    `json:"host"`            // Proxmox host (e.g., https://proxmox.local:8006)
+	Node           string    `json:"node"`            // Proxmox node name
+	Guest          GuestKind `json:"guest"`           // "qemu" (default) or "lxc"
+	VMID           int       `json:"vmid"`            // VM/container ID for agent
 
 	// Authentication - use either token OR username/password
 	TokenID        string `json:"token_id"`        // API token ID (e.g., root@pam!cupax)
@@ -50,6 +137,95 @@ type ProxmoxConfig struct {
 	VerifySSL      bool   `json:"verify_ssl"`      // Verify SSL certificate
 	RestoreSnapshot bool   `json:"restore_snapshot"` // Restore to latest snapshot before analysis
 	ShutdownAfter  bool   `json:"shutdown_after"`  // Shutdown VM after analysis completes
+
+	// RenewInterval controls how often a username/password client renews its
+	// auth ticket in the background, in seconds. PVE tickets expire after
+	// ~2 hours; 0 falls back to a default of 105 minutes (15 minutes of
+	// margin before expiry). Unused for token authentication.
+	RenewInterval int `json:"ticket_renew_interval_seconds"`
+
+	// StatusPollInterval controls how often WaitForStatus/WaitForTask poll,
+	// in seconds. 0 falls back to 2 seconds. LXC containers start and stop
+	// noticeably faster than QEMU VMs, so a container-only deployment may
+	// want this tighter than the default.
+	StatusPollInterval int `json:"status_poll_interval_seconds"`
+
+	// SnapshotRetention configures the background sweep that prunes
+	// pre-detonation snapshots via Client.ApplyRetention, a prerequisite
+	// for parallel VM usage - without it, concurrent analyses accumulate
+	// snapshots without bound. A zero value disables the sweep.
+	SnapshotRetention SnapshotRetentionConfig `json:"snapshot_retention"`
+}
+
+// SnapshotRetentionConfig controls proxmox.RetentionWorker's periodic sweep.
+// MaxCount and MaxAgeDays are both zero (disabled) by default; set either to
+// enable pruning.
+type SnapshotRetentionConfig struct {
+	MaxCount   int      `json:"max_count"`    // keep at most this many snapshots (0 disables count-based pruning)
+	MaxAgeDays int      `json:"max_age_days"` // prune snapshots older than this many days (0 disables age-based pruning)
+	KeepNames  []string `json:"keep_names"`   // snapshot names never pruned, e.g. a curated "golden" baseline
+
+	// IntervalMinutes controls how often the sweep runs; 0 falls back to 60.
+	IntervalMinutes int `json:"interval_minutes"`
+}
+
+// SandboxConfig selects which backend controls the sandbox guest's
+// lifecycle (snapshot/rollback/start/stop) around each analysis; see
+// internal/sandbox. "proxmox" (the default) reads Proxmox above; "libvirt"
+// and "virtualbox" read their own nested config below; "external" disables
+// VM control entirely, for a bare hardware sandbox or any setup where the
+// agent manages its own lifecycle.
+type SandboxConfig struct {
+	Backend    string           `json:"backend"` // "proxmox" (default), "libvirt", "virtualbox", "external"
+	Libvirt    LibvirtConfig    `json:"libvirt"`
+	VirtualBox VirtualBoxConfig `json:"virtualbox"`
+}
+
+// LibvirtConfig names the libvirt connection and domain the libvirt
+// sandbox backend controls.
+type LibvirtConfig struct {
+	URI        string `json:"uri"`         // libvirt connection URI, e.g. qemu:///system
+	DomainName string `json:"domain_name"` // libvirt domain (VM) name
+}
+
+// VirtualBoxConfig names the VM and VBoxManage binary the virtualbox
+// sandbox backend controls.
+type VirtualBoxConfig struct {
+	VMName         string `json:"vm_name"`          // VBoxManage VM name or UUID
+	VBoxManagePath string `json:"vboxmanage_path"` // path to VBoxManage; "" uses $PATH
+}
+
+// RetentionConfig holds analysis archive/retention configuration
+type RetentionConfig struct {
+	Enabled          bool   `json:"enabled"`            // Enable/disable the background retention worker
+	ArchiveDir       string `json:"archive_dir"`         // Directory compressed archive files are written to
+	ArchiveAfterDays int    `json:"archive_after_days"`  // Archive completed analyses older than this many days
+	PurgeAfterDays   int    `json:"purge_after_days"`    // Purge archives older than this many days (0 disables purging)
+}
+
+// MetricsConfig controls the Prometheus metrics surface. Namespace and
+// Subsystem are prefixed onto every metric name (e.g. namespace
+// "cupax", subsystem "analyzer" -> "cupax_analyzer_uploads_total"),
+// mirroring the usual prometheus.Opts convention. ListenAddr, if set,
+// serves /metrics on its own listener (e.g. "127.0.0.1:9091") instead of
+// the main API port, so it can be bound to a private interface; left
+// blank, /metrics is served alongside the API on Server.Port.
+type MetricsConfig struct {
+	Enabled    bool   `json:"enabled"`
+	Namespace  string `json:"namespace"`
+	Subsystem  string `json:"subsystem"`
+	ListenAddr string `json:"listen_addr"`
+}
+
+// WhitelistsConfig controls trust for signed whitelist import bundles (see
+// internal/whitelistbundle). TrustedKeys maps a signature's key_id to its
+// hex-encoded ed25519 public key. AllowUnsigned lets POST
+// /api/v1/whitelists/import accept a bundle with no signature at all; a
+// present-but-invalid signature is always rejected regardless of this
+// setting.
+type WhitelistsConfig struct {
+	TrustedKeys   map[string]string `json:"trusted_keys"`
+	AllowUnsigned bool              `json:"allow_unsigned"`
 }
 
 // Load loads configuration from a JSON file
@@ -63,16 +239,23 @@ func Load(filepath string) (*Config, error) {
 		Data: DataConfig{
 			BaseDir:    "./data",
 			SamplesDir: "./data/samples",
+			Storage: StorageConfig{
+				Backend: "fs",
+			},
 		},
 		Analyzer: AnalyzerConfig{
-			Enabled:  false,
-			AgentURL: "http://localhost:9090",
-			Timeout:  300,
+			Enabled: false,
+			Timeout: 300,
+			Workers: 4,
+			AgentPool: []agentpool.AgentSpec{
+				{Name: "default", URL: "http://localhost:9090", Weight: 0, MaxConcurrent: 1},
+			},
 		},
 		Proxmox: ProxmoxConfig{
 			Enabled:         false,
 			Host:            "https://proxmox.local:8006",
 			Node:            "pve",
+			Guest:           GuestQEMU,
 			VMID:            100,
 			TokenID:         "",
 			TokenSecret:     "",
@@ -82,6 +265,23 @@ func Load(filepath string) (*Config, error) {
 			RestoreSnapshot: true,
 			ShutdownAfter:   true,
 		},
+		Sandbox: SandboxConfig{
+			Backend: "proxmox",
+		},
+		Retention: RetentionConfig{
+			Enabled:          false,
+			ArchiveDir:       "./data/archive",
+			ArchiveAfterDays: 90,
+			PurgeAfterDays:   365,
+		},
+		Metrics: MetricsConfig{
+			Enabled:   false,
+			Namespace: "cupax",
+		},
+		Whitelists: WhitelistsConfig{
+			TrustedKeys:   map[string]string{},
+			AllowUnsigned: false,
+		},
 	}
 
 	// Try to load config file
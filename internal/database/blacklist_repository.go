@@ -0,0 +1,261 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cupax/cupax/internal/models"
+	"github.com/google/uuid"
+)
+
+// CreateBlacklist creates a new blacklist/IOC entry
+func (r *Repository) CreateBlacklist(bl *models.Blacklist) error {
+	query := `
+		INSERT INTO blacklists (id, type, value, description, severity, is_regex, is_cidr, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.DB.Exec(
+		query,
+		bl.ID.String(),
+		bl.Type,
+		bl.Value,
+		bl.Description,
+		bl.Severity,
+		bl.IsRegex,
+		bl.IsCIDR,
+		bl.Enabled,
+		bl.CreatedAt,
+		bl.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create blacklist: %w", err)
+	}
+
+	return nil
+}
+
+// GetBlacklistByID retrieves a blacklist entry by ID
+func (r *Repository) GetBlacklistByID(id uuid.UUID) (*models.Blacklist, error) {
+	query := `
+		SELECT id, type, value, description, severity, is_regex, is_cidr, enabled, created_at, updated_at
+		FROM blacklists
+		WHERE id = ?
+	`
+
+	var bl models.Blacklist
+	var idStr string
+
+	err := r.db.DB.QueryRow(query, id.String()).Scan(
+		&idStr,
+		&bl.Type,
+		&bl.Value,
+		&bl.Description,
+		&bl.Severity,
+		&bl.IsRegex,
+		&bl.IsCIDR,
+		&bl.Enabled,
+		&bl.CreatedAt,
+		&bl.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("blacklist not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blacklist: %w", err)
+	}
+
+	bl.ID, _ = uuid.Parse(idStr)
+
+	return &bl, nil
+}
+
+// GetAllBlacklists retrieves all blacklist entries
+func (r *Repository) GetAllBlacklists() ([]models.Blacklist, error) {
+	query := `
+		SELECT id, type, value, description, severity, is_regex, is_cidr, enabled, created_at, updated_at
+		FROM blacklists
+		ORDER BY type, value
+	`
+
+	rows, err := r.db.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blacklists: %w", err)
+	}
+	defer rows.Close()
+
+	var blacklists []models.Blacklist
+	for rows.Next() {
+		var bl models.Blacklist
+		var idStr string
+
+		if err := rows.Scan(
+			&idStr,
+			&bl.Type,
+			&bl.Value,
+			&bl.Description,
+			&bl.Severity,
+			&bl.IsRegex,
+			&bl.IsCIDR,
+			&bl.Enabled,
+			&bl.CreatedAt,
+			&bl.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan blacklist: %w", err)
+		}
+
+		bl.ID, _ = uuid.Parse(idStr)
+		blacklists = append(blacklists, bl)
+	}
+
+	return blacklists, nil
+}
+
+// GetEnabledBlacklists retrieves all enabled blacklist entries
+func (r *Repository) GetEnabledBlacklists() ([]models.Blacklist, error) {
+	query := `
+		SELECT id, type, value, description, severity, is_regex, is_cidr, enabled, created_at, updated_at
+		FROM blacklists
+		WHERE enabled = 1
+		ORDER BY type, value
+	`
+
+	rows, err := r.db.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query enabled blacklists: %w", err)
+	}
+	defer rows.Close()
+
+	var blacklists []models.Blacklist
+	for rows.Next() {
+		var bl models.Blacklist
+		var idStr string
+
+		if err := rows.Scan(
+			&idStr,
+			&bl.Type,
+			&bl.Value,
+			&bl.Description,
+			&bl.Severity,
+			&bl.IsRegex,
+			&bl.IsCIDR,
+			&bl.Enabled,
+			&bl.CreatedAt,
+			&bl.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan blacklist: %w", err)
+		}
+
+		bl.ID, _ = uuid.Parse(idStr)
+		blacklists = append(blacklists, bl)
+	}
+
+	return blacklists, nil
+}
+
+// GetWhitelistByTypeValue retrieves a whitelist entry by its exact type and
+// value, used to resolve whitelist/blacklist conflicts on the same
+// indicator
+func (r *Repository) GetWhitelistByTypeValue(whitelistType models.WhitelistType, value string) (*models.Whitelist, error) {
+	query := `
+		SELECT id, type, value, description, is_regex, enabled, source, created_at, updated_at
+		FROM whitelists
+		WHERE type = ? AND value = ? AND enabled = 1
+	`
+
+	var wl models.Whitelist
+	var idStr string
+
+	err := r.db.DB.QueryRow(query, whitelistType, value).Scan(
+		&idStr,
+		&wl.Type,
+		&wl.Value,
+		&wl.Description,
+		&wl.IsRegex,
+		&wl.Enabled,
+		&wl.Source,
+		&wl.CreatedAt,
+		&wl.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get whitelist by type/value: %w", err)
+	}
+
+	wl.ID, _ = uuid.Parse(idStr)
+
+	return &wl, nil
+}
+
+// UpdateBlacklist updates a blacklist entry
+func (r *Repository) UpdateBlacklist(id uuid.UUID, update *models.BlacklistUpdate) error {
+	query := "UPDATE blacklists SET updated_at = ?"
+	args := []interface{}{time.Now()}
+
+	if update.Value != nil {
+		query += ", value = ?"
+		args = append(args, *update.Value)
+	}
+	if update.Description != nil {
+		query += ", description = ?"
+		args = append(args, *update.Description)
+	}
+	if update.Severity != nil {
+		query += ", severity = ?"
+		args = append(args, *update.Severity)
+	}
+	if update.IsRegex != nil {
+		query += ", is_regex = ?"
+		args = append(args, *update.IsRegex)
+	}
+	if update.IsCIDR != nil {
+		query += ", is_cidr = ?"
+		args = append(args, *update.IsCIDR)
+	}
+	if update.Enabled != nil {
+		query += ", enabled = ?"
+		args = append(args, *update.Enabled)
+	}
+
+	query += " WHERE id = ?"
+	args = append(args, id.String())
+
+	result, err := r.db.DB.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update blacklist: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("blacklist not found")
+	}
+
+	return nil
+}
+
+// DeleteBlacklist deletes a blacklist entry
+func (r *Repository) DeleteBlacklist(id uuid.UUID) error {
+	result, err := r.db.DB.Exec(`DELETE FROM blacklists WHERE id = ?`, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete blacklist: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("blacklist not found")
+	}
+
+	return nil
+}
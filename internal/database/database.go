@@ -5,10 +5,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// DefaultQueryTimeout bounds how long any …Context repository method waits
+// on a query before giving up, when the caller's context carries no earlier
+// deadline of its own. Operators needing a different worst-case latency can
+// override this at process startup.
+var DefaultQueryTimeout = 30 * time.Second
+
 // Database wraps the sql.DB connection
 type Database struct {
 	DB *sql.DB
@@ -64,12 +72,13 @@ func (d *Database) InitSchema() error {
 		id TEXT PRIMARY KEY,
 		filename TEXT NOT NULL,
 		file_hash_sha256 TEXT NOT NULL UNIQUE,
+		sample_uri TEXT NOT NULL DEFAULT '',
 		status TEXT NOT NULL DEFAULT 'running',
 		submitted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		completed_at DATETIME,
 		report_json TEXT,
 		error_message TEXT,
-		CHECK (status IN ('running', 'completed', 'error'))
+		CHECK (status IN ('queued', 'running', 'completed', 'error'))
 	);
 
 	-- Create index on status for faster filtering
@@ -88,10 +97,12 @@ func (d *Database) InitSchema() error {
 		value TEXT NOT NULL,
 		description TEXT,
 		is_regex INTEGER NOT NULL DEFAULT 0,
+		is_cidr INTEGER NOT NULL DEFAULT 0,
 		enabled INTEGER NOT NULL DEFAULT 1,
+		source TEXT NOT NULL DEFAULT '',
 		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		CHECK (type IN ('process', 'domain', 'ip', 'registry'))
+		CHECK (type IN ('process', 'domain', 'ip', 'registry', 'hash'))
 	);
 
 	-- Create index on type for faster filtering
@@ -100,8 +111,74 @@ func (d *Database) InitSchema() error {
 	-- Create index on enabled for faster filtering
 	CREATE INDEX IF NOT EXISTS idx_whitelists_enabled ON whitelists(enabled);
 
+	-- Create index on source for feed re-sync/purge
+	CREATE INDEX IF NOT EXISTS idx_whitelists_source ON whitelists(source);
+
 	-- Create unique index on type+value to prevent duplicates
 	CREATE UNIQUE INDEX IF NOT EXISTS idx_whitelists_type_value ON whitelists(type, value);
+
+	-- Create threat-intel feed table
+	CREATE TABLE IF NOT EXISTS threat_feeds (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		url TEXT NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		etag TEXT NOT NULL DEFAULT '',
+		last_modified TEXT NOT NULL DEFAULT '',
+		last_synced_at DATETIME,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		CHECK (kind IN ('stix', 'misp'))
+	);
+
+	-- Create blacklist/IOC table
+	CREATE TABLE IF NOT EXISTS blacklists (
+		id TEXT PRIMARY KEY,
+		type TEXT NOT NULL,
+		value TEXT NOT NULL,
+		description TEXT,
+		severity INTEGER NOT NULL DEFAULT 50,
+		is_regex INTEGER NOT NULL DEFAULT 0,
+		is_cidr INTEGER NOT NULL DEFAULT 0,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		CHECK (type IN ('process', 'domain', 'ip', 'registry', 'sha256', 'mutex')),
+		CHECK (severity BETWEEN 0 AND 100)
+	);
+
+	-- Create index on type for faster filtering
+	CREATE INDEX IF NOT EXISTS idx_blacklists_type ON blacklists(type);
+
+	-- Create index on enabled for faster filtering
+	CREATE INDEX IF NOT EXISTS idx_blacklists_enabled ON blacklists(enabled);
+
+	-- Create unique index on type+value to prevent duplicates
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_blacklists_type_value ON blacklists(type, value);
+
+	-- Tracks a monotonic version for the whitelists table so filter.WhitelistFilter
+	-- can skip rebuilding its compiled index when nothing has changed.
+	CREATE TABLE IF NOT EXISTS whitelist_meta (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		version INTEGER NOT NULL DEFAULT 0
+	);
+	INSERT OR IGNORE INTO whitelist_meta (id, version) VALUES (1, 0);
+
+	CREATE TRIGGER IF NOT EXISTS trg_whitelists_version_insert AFTER INSERT ON whitelists
+	BEGIN
+		UPDATE whitelist_meta SET version = version + 1 WHERE id = 1;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS trg_whitelists_version_update AFTER UPDATE ON whitelists
+	BEGIN
+		UPDATE whitelist_meta SET version = version + 1 WHERE id = 1;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS trg_whitelists_version_delete AFTER DELETE ON whitelists
+	BEGIN
+		UPDATE whitelist_meta SET version = version + 1 WHERE id = 1;
+	END;
 	`
 
 	_, err := d.DB.Exec(schema)
@@ -109,5 +186,105 @@ func (d *Database) InitSchema() error {
 		return fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
+	// Best-effort migration for databases created before the source column
+	// existed. SQLite has no "ADD COLUMN IF NOT EXISTS", so ignore the
+	// "duplicate column" error on an already-migrated database.
+	if _, err := d.DB.Exec(`ALTER TABLE whitelists ADD COLUMN source TEXT NOT NULL DEFAULT ''`); err != nil {
+		if !strings.Contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("failed to migrate whitelists.source column: %w", err)
+		}
+	}
+	if _, err := d.DB.Exec(`ALTER TABLE whitelists ADD COLUMN is_cidr INTEGER NOT NULL DEFAULT 0`); err != nil {
+		if !strings.Contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("failed to migrate whitelists.is_cidr column: %w", err)
+		}
+	}
+	if _, err := d.DB.Exec(`ALTER TABLE analyses ADD COLUMN archived_at DATETIME`); err != nil {
+		if !strings.Contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("failed to migrate analyses.archived_at column: %w", err)
+		}
+	}
+	if _, err := d.DB.Exec(`ALTER TABLE analyses ADD COLUMN archive_path TEXT NOT NULL DEFAULT ''`); err != nil {
+		if !strings.Contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("failed to migrate analyses.archive_path column: %w", err)
+		}
+	}
+	if _, err := d.DB.Exec(`ALTER TABLE analyses ADD COLUMN sample_uri TEXT NOT NULL DEFAULT ''`); err != nil {
+		if !strings.Contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("failed to migrate analyses.sample_uri column: %w", err)
+		}
+	}
+
+	// Index for the retention worker's "archive anything older than N days"
+	// and "purge anything archived more than M days ago" scans.
+	if _, err := d.DB.Exec(`CREATE INDEX IF NOT EXISTS idx_analyses_archived_at ON analyses(archived_at)`); err != nil {
+		return fmt.Errorf("failed to create analyses.archived_at index: %w", err)
+	}
+
+	// The analyses.status CHECK constraint above now allows 'queued' for
+	// the async upload queue, but SQLite has no ALTER TABLE for CHECK
+	// constraints, so a database created before this change still has the
+	// old constraint and rejects 'queued' until rebuilt.
+	if err := d.migrateAnalysesStatusCheck(); err != nil {
+		return fmt.Errorf("failed to migrate analyses.status constraint: %w", err)
+	}
+
 	return nil
 }
+
+// migrateAnalysesStatusCheck rebuilds the analyses table for databases
+// created before 'queued' was added to its status CHECK constraint, using
+// the standard SQLite "create new table, copy rows, drop old, rename"
+// dance - SQLite has no way to widen a CHECK constraint in place. A no-op
+// on a fresh database or one already rebuilt.
+func (d *Database) migrateAnalysesStatusCheck() error {
+	var tableSQL string
+	err := d.DB.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'analyses'`).Scan(&tableSQL)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect analyses table: %w", err)
+	}
+	if strings.Contains(tableSQL, "'queued'") {
+		return nil
+	}
+
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`ALTER TABLE analyses RENAME TO analyses_old`,
+		`CREATE TABLE analyses (
+			id TEXT PRIMARY KEY,
+			filename TEXT NOT NULL,
+			file_hash_sha256 TEXT NOT NULL UNIQUE,
+			sample_uri TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'running',
+			submitted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			completed_at DATETIME,
+			report_json TEXT,
+			error_message TEXT,
+			archived_at DATETIME,
+			archive_path TEXT NOT NULL DEFAULT '',
+			CHECK (status IN ('queued', 'running', 'completed', 'error'))
+		)`,
+		`INSERT INTO analyses (id, filename, file_hash_sha256, sample_uri, status, submitted_at, completed_at, report_json, error_message, archived_at, archive_path)
+			SELECT id, filename, file_hash_sha256, sample_uri, status, submitted_at, completed_at, report_json, error_message, archived_at, archive_path FROM analyses_old`,
+		`DROP TABLE analyses_old`,
+		`CREATE INDEX IF NOT EXISTS idx_analyses_status ON analyses(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_analyses_submitted_at ON analyses(submitted_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_analyses_file_hash ON analyses(file_hash_sha256)`,
+		`CREATE INDEX IF NOT EXISTS idx_analyses_archived_at ON analyses(archived_at)`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to rebuild analyses table: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
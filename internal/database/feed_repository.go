@@ -0,0 +1,224 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cupax/cupax/internal/models"
+	"github.com/google/uuid"
+)
+
+// CreateThreatFeed registers a new threat-intel feed subscription
+func (r *Repository) CreateThreatFeed(feed *models.ThreatFeed) error {
+	query := `
+		INSERT INTO threat_feeds (id, name, kind, url, enabled, etag, last_modified, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.DB.Exec(
+		query,
+		feed.ID.String(),
+		feed.Name,
+		feed.Kind,
+		feed.URL,
+		feed.Enabled,
+		feed.ETag,
+		feed.LastModified,
+		feed.CreatedAt,
+		feed.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create threat feed: %w", err)
+	}
+
+	return nil
+}
+
+// GetThreatFeedByID retrieves a threat feed by ID
+func (r *Repository) GetThreatFeedByID(id uuid.UUID) (*models.ThreatFeed, error) {
+	query := `
+		SELECT id, name, kind, url, enabled, etag, last_modified, last_synced_at, created_at, updated_at
+		FROM threat_feeds
+		WHERE id = ?
+	`
+
+	var feed models.ThreatFeed
+	var idStr string
+	var lastSyncedAt sql.NullTime
+
+	err := r.db.DB.QueryRow(query, id.String()).Scan(
+		&idStr,
+		&feed.Name,
+		&feed.Kind,
+		&feed.URL,
+		&feed.Enabled,
+		&feed.ETag,
+		&feed.LastModified,
+		&lastSyncedAt,
+		&feed.CreatedAt,
+		&feed.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("threat feed not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get threat feed: %w", err)
+	}
+
+	feed.ID, _ = uuid.Parse(idStr)
+	if lastSyncedAt.Valid {
+		feed.LastSyncedAt = &lastSyncedAt.Time
+	}
+
+	return &feed, nil
+}
+
+// GetEnabledThreatFeeds retrieves every feed the poller should refresh
+func (r *Repository) GetEnabledThreatFeeds() ([]models.ThreatFeed, error) {
+	query := `
+		SELECT id, name, kind, url, enabled, etag, last_modified, last_synced_at, created_at, updated_at
+		FROM threat_feeds
+		WHERE enabled = 1
+		ORDER BY name
+	`
+
+	rows, err := r.db.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query enabled threat feeds: %w", err)
+	}
+	defer rows.Close()
+
+	var feeds []models.ThreatFeed
+	for rows.Next() {
+		var feed models.ThreatFeed
+		var idStr string
+		var lastSyncedAt sql.NullTime
+
+		if err := rows.Scan(
+			&idStr,
+			&feed.Name,
+			&feed.Kind,
+			&feed.URL,
+			&feed.Enabled,
+			&feed.ETag,
+			&feed.LastModified,
+			&lastSyncedAt,
+			&feed.CreatedAt,
+			&feed.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan threat feed: %w", err)
+		}
+
+		feed.ID, _ = uuid.Parse(idStr)
+		if lastSyncedAt.Valid {
+			feed.LastSyncedAt = &lastSyncedAt.Time
+		}
+		feeds = append(feeds, feed)
+	}
+
+	return feeds, nil
+}
+
+// UpdateThreatFeedCache records the ETag/Last-Modified validators and sync
+// time returned by the last successful poll of a feed
+func (r *Repository) UpdateThreatFeedCache(id uuid.UUID, etag, lastModified string, syncedAt time.Time) error {
+	query := `
+		UPDATE threat_feeds
+		SET etag = ?, last_modified = ?, last_synced_at = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	result, err := r.db.DB.Exec(query, etag, lastModified, syncedAt, time.Now(), id.String())
+	if err != nil {
+		return fmt.Errorf("failed to update threat feed cache: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("threat feed not found")
+	}
+
+	return nil
+}
+
+// DeleteThreatFeed removes a feed subscription. It does not purge the
+// whitelist entries it produced; call DeleteWhitelistsBySource for that.
+func (r *Repository) DeleteThreatFeed(id uuid.UUID) error {
+	result, err := r.db.DB.Exec(`DELETE FROM threat_feeds WHERE id = ?`, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete threat feed: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("threat feed not found")
+	}
+
+	return nil
+}
+
+// SyncFromFeed replaces every whitelist entry tagged with feedID's source
+// with the freshly imported set, so a feed can be re-synced or purged as a
+// group. It runs inside a transaction: the old rows for the source are
+// deleted and the new ones inserted, or neither happens.
+func (r *Repository) SyncFromFeed(feedID uuid.UUID, entries []models.Whitelist) (added int, err error) {
+	source := feedID.String()
+
+	tx, err := r.db.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin sync transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM whitelists WHERE source = ?`, source); err != nil {
+		return 0, fmt.Errorf("failed to clear previous feed entries: %w", err)
+	}
+
+	insert := `
+		INSERT INTO whitelists (id, type, value, description, is_regex, enabled, source, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (type, value) DO UPDATE SET
+			description = excluded.description,
+			is_regex = excluded.is_regex,
+			enabled = excluded.enabled,
+			source = excluded.source,
+			updated_at = excluded.updated_at
+	`
+
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.ID == uuid.Nil {
+			entry.ID = uuid.New()
+		}
+		if _, err := tx.Exec(
+			insert,
+			entry.ID.String(),
+			entry.Type,
+			entry.Value,
+			entry.Description,
+			entry.IsRegex,
+			entry.Enabled,
+			source,
+			now,
+			now,
+		); err != nil {
+			return 0, fmt.Errorf("failed to upsert feed entry %q: %w", entry.Value, err)
+		}
+		added++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit sync transaction: %w", err)
+	}
+
+	return added, nil
+}
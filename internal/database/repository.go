@@ -1,37 +1,72 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"time"
 
+	"github.com/cupax/cupax/internal/metrics"
 	"github.com/cupax/cupax/internal/models"
 	"github.com/google/uuid"
+	"github.com/hashicorp/go-hclog"
 )
 
+// DefaultAnalysisPageSize is used by ListAnalyses when ListOptions.PageSize
+// is unset.
+const DefaultAnalysisPageSize = 50
+
 // Repository provides database operations for analyses
 type Repository struct {
-	db *Database
+	db      *Database
+	logger  hclog.Logger
+	metrics *metrics.Metrics
+}
+
+// NewRepository creates a new repository instance. m may be nil, in which
+// case query timing isn't exported as a metric.
+func NewRepository(db *Database, logger hclog.Logger, m *metrics.Metrics) *Repository {
+	return &Repository{db: db, logger: logger, metrics: m}
 }
 
-// NewRepository creates a new repository instance
-func NewRepository(db *Database) *Repository {
-	return &Repository{db: db}
+// timeQuery records how long the query named name took to run, for
+// QueryDuration. Call via defer right after entering a *Context method:
+// defer r.timeQuery("get_analysis_by_id")()
+func (r *Repository) timeQuery(name string) func() {
+	start := time.Now()
+	return func() {
+		if r.metrics != nil {
+			r.metrics.ObserveDBQuery(name, time.Since(start))
+		}
+	}
 }
 
 // CreateAnalysis creates a new analysis record
 func (r *Repository) CreateAnalysis(analysis *models.Analysis) error {
+	return r.CreateAnalysisContext(context.Background(), analysis)
+}
+
+// CreateAnalysisContext is CreateAnalysis with caller-controlled cancellation
+// and deadline.
+func (r *Repository) CreateAnalysisContext(ctx context.Context, analysis *models.Analysis) error {
+	defer r.timeQuery("create_analysis")()
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := `
-		INSERT INTO analyses (id, filename, file_hash_sha256, status, submitted_at)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO analyses (id, filename, file_hash_sha256, sample_uri, status, submitted_at)
+		VALUES (?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.DB.Exec(
+	_, err := r.db.DB.ExecContext(
+		ctx,
 		query,
 		analysis.ID.String(),
 		analysis.Filename,
 		analysis.FileHashSHA256,
+		analysis.SampleURI,
 		analysis.Status,
 		analysis.SubmittedAt,
 	)
@@ -45,8 +80,19 @@ func (r *Repository) CreateAnalysis(analysis *models.Analysis) error {
 
 // GetAnalysisByID retrieves an analysis by ID
 func (r *Repository) GetAnalysisByID(id uuid.UUID) (*models.Analysis, error) {
+	return r.GetAnalysisByIDContext(context.Background(), id)
+}
+
+// GetAnalysisByIDContext is GetAnalysisByID with caller-controlled
+// cancellation and deadline.
+func (r *Repository) GetAnalysisByIDContext(ctx context.Context, id uuid.UUID) (*models.Analysis, error) {
+	defer r.timeQuery("get_analysis_by_id")()
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, filename, file_hash_sha256, status, submitted_at, completed_at, report_json, error_message
+		SELECT id, filename, file_hash_sha256, sample_uri, status, submitted_at, completed_at, report_json, error_message, archived_at, archive_path
 		FROM analyses
 		WHERE id = ?
 	`
@@ -56,16 +102,20 @@ func (r *Repository) GetAnalysisByID(id uuid.UUID) (*models.Analysis, error) {
 	var reportJSON sql.NullString
 	var completedAt sql.NullTime
 	var errorMessage sql.NullString
+	var archivedAt sql.NullTime
 
-	err := r.db.DB.QueryRow(query, id.String()).Scan(
+	err := r.db.DB.QueryRowContext(ctx, query, id.String()).Scan(
 		&idStr,
 		&analysis.Filename,
 		&analysis.FileHashSHA256,
+		&analysis.SampleURI,
 		&analysis.Status,
 		&analysis.SubmittedAt,
 		&completedAt,
 		&reportJSON,
 		&errorMessage,
+		&archivedAt,
+		&analysis.ArchivePath,
 	)
 
 	if err == sql.ErrNoRows {
@@ -91,6 +141,10 @@ func (r *Repository) GetAnalysisByID(id uuid.UUID) (*models.Analysis, error) {
 		analysis.ErrorMessage = &errorMessage.String
 	}
 
+	if archivedAt.Valid {
+		analysis.ArchivedAt = &archivedAt.Time
+	}
+
 	// Unmarshal JSON report
 	if reportJSON.Valid && reportJSON.String != "" {
 		var report models.AnalysisReport
@@ -105,8 +159,19 @@ func (r *Repository) GetAnalysisByID(id uuid.UUID) (*models.Analysis, error) {
 
 // GetAnalysisByHash retrieves an analysis by SHA256 hash
 func (r *Repository) GetAnalysisByHash(hash string) (*models.Analysis, error) {
+	return r.GetAnalysisByHashContext(context.Background(), hash)
+}
+
+// GetAnalysisByHashContext is GetAnalysisByHash with caller-controlled
+// cancellation and deadline.
+func (r *Repository) GetAnalysisByHashContext(ctx context.Context, hash string) (*models.Analysis, error) {
+	defer r.timeQuery("get_analysis_by_hash")()
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, filename, file_hash_sha256, status, submitted_at, completed_at, report_json, error_message
+		SELECT id, filename, file_hash_sha256, sample_uri, status, submitted_at, completed_at, report_json, error_message, archived_at, archive_path
 		FROM analyses
 		WHERE file_hash_sha256 = ?
 	`
@@ -116,16 +181,20 @@ func (r *Repository) GetAnalysisByHash(hash string) (*models.Analysis, error) {
 	var reportJSON sql.NullString
 	var completedAt sql.NullTime
 	var errorMessage sql.NullString
+	var archivedAt sql.NullTime
 
-	err := r.db.DB.QueryRow(query, hash).Scan(
+	err := r.db.DB.QueryRowContext(ctx, query, hash).Scan(
 		&idStr,
 		&analysis.Filename,
 		&analysis.FileHashSHA256,
+		&analysis.SampleURI,
 		&analysis.Status,
 		&analysis.SubmittedAt,
 		&completedAt,
 		&reportJSON,
 		&errorMessage,
+		&archivedAt,
+		&analysis.ArchivePath,
 	)
 
 	if err == sql.ErrNoRows {
@@ -151,6 +220,10 @@ func (r *Repository) GetAnalysisByHash(hash string) (*models.Analysis, error) {
 		analysis.ErrorMessage = &errorMessage.String
 	}
 
+	if archivedAt.Valid {
+		analysis.ArchivedAt = &archivedAt.Time
+	}
+
 	// Unmarshal JSON report
 	if reportJSON.Valid && reportJSON.String != "" {
 		var report models.AnalysisReport
@@ -163,17 +236,61 @@ func (r *Repository) GetAnalysisByHash(hash string) (*models.Analysis, error) {
 	return &analysis, nil
 }
 
-// GetAllAnalyses retrieves all analyses ordered by submission time
-func (r *Repository) GetAllAnalyses() ([]models.Analysis, error) {
-	query := `
-		SELECT id, filename, file_hash_sha256, status, submitted_at, completed_at, report_json, error_message
-		FROM analyses
-		ORDER BY submitted_at DESC
-	`
+// ListAnalyses returns a keyset-paginated, filtered page of analyses.
+// Unlike GetAllAnalyses it never loads the whole table into memory: it
+// fetches at most opts.PageSize+1 rows (the extra row just tells us
+// whether another page follows) and, unless opts.IncludeReport is set,
+// never selects or decodes report_json at all.
+func (r *Repository) ListAnalyses(ctx context.Context, opts models.ListOptions) (*models.Page[models.Analysis], error) {
+	defer r.timeQuery("list_analyses")()
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultAnalysisPageSize
+	}
+
+	columns := "id, filename, file_hash_sha256, sample_uri, status, submitted_at, completed_at, error_message, archived_at, archive_path"
+	if opts.IncludeReport {
+		columns += ", report_json"
+	}
 
-	rows, err := r.db.DB.Query(query)
+	query := "SELECT " + columns + " FROM analyses WHERE 1=1"
+	var args []interface{}
+
+	if opts.Status != nil {
+		query += " AND status = ?"
+		args = append(args, *opts.Status)
+	}
+	if opts.FilenameLike != "" {
+		query += " AND filename LIKE ?"
+		args = append(args, "%"+opts.FilenameLike+"%")
+	}
+	if opts.HashPrefix != "" {
+		query += " AND file_hash_sha256 LIKE ?"
+		args = append(args, opts.HashPrefix+"%")
+	}
+	if opts.SubmittedAfter != nil {
+		query += " AND submitted_at > ?"
+		args = append(args, *opts.SubmittedAfter)
+	}
+	if opts.SubmittedBefore != nil {
+		query += " AND submitted_at < ?"
+		args = append(args, *opts.SubmittedBefore)
+	}
+	if opts.After != nil {
+		query += " AND (submitted_at < ? OR (submitted_at = ? AND id < ?))"
+		args = append(args, opts.After.SubmittedAt, opts.After.SubmittedAt, opts.After.ID.String())
+	}
+
+	query += " ORDER BY submitted_at DESC, id DESC LIMIT ?"
+	args = append(args, pageSize+1)
+
+	rows, err := r.db.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query analyses: %w", err)
+		return nil, fmt.Errorf("failed to list analyses: %w", err)
 	}
 	defer rows.Close()
 
@@ -181,21 +298,28 @@ func (r *Repository) GetAllAnalyses() ([]models.Analysis, error) {
 	for rows.Next() {
 		var analysis models.Analysis
 		var idStr string
-		var reportJSON sql.NullString
 		var completedAt sql.NullTime
 		var errorMessage sql.NullString
+		var archivedAt sql.NullTime
+		var reportJSON sql.NullString
 
-		err := rows.Scan(
+		dest := []interface{}{
 			&idStr,
 			&analysis.Filename,
 			&analysis.FileHashSHA256,
+			&analysis.SampleURI,
 			&analysis.Status,
 			&analysis.SubmittedAt,
 			&completedAt,
-			&reportJSON,
 			&errorMessage,
-		)
-		if err != nil {
+			&archivedAt,
+			&analysis.ArchivePath,
+		}
+		if opts.IncludeReport {
+			dest = append(dest, &reportJSON)
+		}
+
+		if err := rows.Scan(dest...); err != nil {
 			return nil, fmt.Errorf("failed to scan analysis: %w", err)
 		}
 
@@ -211,12 +335,16 @@ func (r *Repository) GetAllAnalyses() ([]models.Analysis, error) {
 			analysis.CompletedAt = &completedAt.Time
 		}
 
+		if archivedAt.Valid {
+			analysis.ArchivedAt = &archivedAt.Time
+		}
+
 		if errorMessage.Valid {
 			analysis.ErrorMessage = &errorMessage.String
 		}
 
 		// Unmarshal JSON report
-		if reportJSON.Valid && reportJSON.String != "" {
+		if opts.IncludeReport && reportJSON.Valid && reportJSON.String != "" {
 			var report models.AnalysisReport
 			if err := json.Unmarshal([]byte(reportJSON.String), &report); err != nil {
 				return nil, fmt.Errorf("failed to unmarshal report JSON: %w", err)
@@ -226,19 +354,70 @@ func (r *Repository) GetAllAnalyses() ([]models.Analysis, error) {
 
 		analyses = append(analyses, analysis)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate analyses: %w", err)
+	}
+
+	page := &models.Page[models.Analysis]{}
+	if len(analyses) > pageSize {
+		last := analyses[pageSize-1]
+		page.NextCursor = &models.AnalysisCursor{SubmittedAt: last.SubmittedAt, ID: last.ID}
+		analyses = analyses[:pageSize]
+	}
+	page.Items = analyses
 
-	return analyses, nil
+	return page, nil
+}
+
+// GetAllAnalyses retrieves all analyses ordered by submission time.
+//
+// Deprecated: loads every row, including every report_json blob, into
+// memory in one pass and will OOM as the analyses table grows. Use
+// ListAnalyses instead.
+func (r *Repository) GetAllAnalyses() ([]models.Analysis, error) {
+	var all []models.Analysis
+	var cursor *models.AnalysisCursor
+
+	for {
+		page, err := r.ListAnalyses(context.Background(), models.ListOptions{
+			After:         cursor,
+			PageSize:      500,
+			IncludeReport: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, page.Items...)
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	return all, nil
 }
 
 // UpdateAnalysisStatus updates the status of an analysis
 func (r *Repository) UpdateAnalysisStatus(id uuid.UUID, status models.AnalysisStatus, message *string) error {
+	return r.UpdateAnalysisStatusContext(context.Background(), id, status, message)
+}
+
+// UpdateAnalysisStatusContext is UpdateAnalysisStatus with caller-controlled
+// cancellation and deadline.
+func (r *Repository) UpdateAnalysisStatusContext(ctx context.Context, id uuid.UUID, status models.AnalysisStatus, message *string) error {
+	defer r.timeQuery("update_analysis_status")()
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := `
 		UPDATE analyses
 		SET status = ?
 		WHERE id = ?
 	`
 
-	result, err := r.db.DB.Exec(query, status, id.String())
+	result, err := r.db.DB.ExecContext(ctx, query, status, id.String())
 	if err != nil {
 		return fmt.Errorf("failed to update analysis status: %w", err)
 	}
@@ -257,6 +436,17 @@ func (r *Repository) UpdateAnalysisStatus(id uuid.UUID, status models.AnalysisSt
 
 // UpdateAnalysisReport updates the report and marks analysis as completed
 func (r *Repository) UpdateAnalysisReport(id uuid.UUID, report *models.AnalysisReport) error {
+	return r.UpdateAnalysisReportContext(context.Background(), id, report)
+}
+
+// UpdateAnalysisReportContext is UpdateAnalysisReport with caller-controlled
+// cancellation and deadline.
+func (r *Repository) UpdateAnalysisReportContext(ctx context.Context, id uuid.UUID, report *models.AnalysisReport) error {
+	defer r.timeQuery("update_analysis_report")()
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	// Marshal report to JSON string
 	reportJSON, err := json.Marshal(report)
 	if err != nil {
@@ -270,7 +460,7 @@ func (r *Repository) UpdateAnalysisReport(id uuid.UUID, report *models.AnalysisR
 	`
 
 	now := time.Now()
-	_, err = r.db.DB.Exec(query, models.StatusCompleted, now, string(reportJSON), id.String())
+	_, err = r.db.DB.ExecContext(ctx, query, models.StatusCompleted, now, string(reportJSON), id.String())
 	if err != nil {
 		return fmt.Errorf("failed to update analysis report: %w", err)
 	}
@@ -280,6 +470,17 @@ func (r *Repository) UpdateAnalysisReport(id uuid.UUID, report *models.AnalysisR
 
 // UpdateAnalysisError updates the analysis with an error message
 func (r *Repository) UpdateAnalysisError(id uuid.UUID, errorMsg string) error {
+	return r.UpdateAnalysisErrorContext(context.Background(), id, errorMsg)
+}
+
+// UpdateAnalysisErrorContext is UpdateAnalysisError with caller-controlled
+// cancellation and deadline.
+func (r *Repository) UpdateAnalysisErrorContext(ctx context.Context, id uuid.UUID, errorMsg string) error {
+	defer r.timeQuery("update_analysis_error")()
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := `
 		UPDATE analyses
 		SET status = ?, completed_at = ?, error_message = ?
@@ -287,7 +488,7 @@ func (r *Repository) UpdateAnalysisError(id uuid.UUID, errorMsg string) error {
 	`
 
 	now := time.Now()
-	_, err := r.db.DB.Exec(query, models.StatusError, now, errorMsg, id.String())
+	_, err := r.db.DB.ExecContext(ctx, query, models.StatusError, now, errorMsg, id.String())
 	if err != nil {
 		return fmt.Errorf("failed to update analysis error: %w", err)
 	}
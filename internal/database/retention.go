@@ -0,0 +1,212 @@
+package database
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cupax/cupax/internal/models"
+	"github.com/google/uuid"
+)
+
+// Archive moves analysis id's report out of the hot report_json column and
+// into a gzip-compressed file under archiveDir, then flips archived_at so
+// callers can distinguish live vs archived records. Only a completed
+// analysis with a report can be archived.
+func (r *Repository) Archive(id uuid.UUID, archiveDir string) error {
+	analysis, err := r.GetAnalysisByID(id)
+	if err != nil {
+		return err
+	}
+	if analysis.IsArchived() {
+		return fmt.Errorf("analysis already archived")
+	}
+	if analysis.ReportJSON == nil {
+		return fmt.Errorf("analysis has no report to archive")
+	}
+
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	archivePath := filepath.Join(archiveDir, id.String()+".json.gz")
+	if err := writeCompressedReport(archivePath, analysis.ReportJSON); err != nil {
+		return fmt.Errorf("failed to write archive file: %w", err)
+	}
+
+	query := `
+		UPDATE analyses
+		SET report_json = NULL, archived_at = ?, archive_path = ?
+		WHERE id = ?
+	`
+	if _, err := r.db.DB.Exec(query, time.Now(), archivePath, id.String()); err != nil {
+		os.Remove(archivePath)
+		return fmt.Errorf("failed to mark analysis archived: %w", err)
+	}
+
+	return nil
+}
+
+// RestoreAnalysis reverses Archive: it reads the compressed report back off
+// disk, restores it into report_json, and clears archived_at/archive_path so
+// the record behaves like a live analysis again. The archive file itself is
+// left on disk; with archive_path cleared, PurgeAnalysis no longer removes
+// it, and a later Archive of the same analysis overwrites it.
+func (r *Repository) RestoreAnalysis(id uuid.UUID) error {
+	analysis, err := r.GetAnalysisByID(id)
+	if err != nil {
+		return err
+	}
+	if !analysis.IsArchived() {
+		return fmt.Errorf("analysis is not archived")
+	}
+
+	report, err := readCompressedReport(analysis.ArchivePath)
+	if err != nil {
+		return fmt.Errorf("failed to read archive file: %w", err)
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal restored report: %w", err)
+	}
+
+	query := `
+		UPDATE analyses
+		SET report_json = ?, archived_at = NULL, archive_path = ''
+		WHERE id = ?
+	`
+	if _, err := r.db.DB.Exec(query, string(reportJSON), id.String()); err != nil {
+		return fmt.Errorf("failed to restore analysis: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeAnalysis permanently deletes an archived analysis: its compressed
+// report on disk and its row in the database. Unlike Archive/RestoreAnalysis
+// this cannot be undone.
+func (r *Repository) PurgeAnalysis(id uuid.UUID) error {
+	analysis, err := r.GetAnalysisByID(id)
+	if err != nil {
+		return err
+	}
+	if !analysis.IsArchived() {
+		return fmt.Errorf("only archived analyses can be purged")
+	}
+
+	if analysis.ArchivePath != "" {
+		if err := os.Remove(analysis.ArchivePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove archive file: %w", err)
+		}
+	}
+
+	if _, err := r.db.DB.Exec(`DELETE FROM analyses WHERE id = ?`, id.String()); err != nil {
+		return fmt.Errorf("failed to delete analysis: %w", err)
+	}
+
+	return nil
+}
+
+// ListArchivableAnalysisIDs returns the IDs of completed analyses that
+// finished before cutoff and have not yet been archived. Used by the
+// retention worker to drive Archive.
+func (r *Repository) ListArchivableAnalysisIDs(cutoff time.Time) ([]uuid.UUID, error) {
+	query := `
+		SELECT id FROM analyses
+		WHERE status = ? AND archived_at IS NULL AND completed_at IS NOT NULL AND completed_at < ?
+	`
+	rows, err := r.db.DB.Query(query, models.StatusCompleted, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archivable analyses: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAnalysisIDs(rows)
+}
+
+// ListPurgeableAnalysisIDs returns the IDs of analyses archived before
+// cutoff. Used by the retention worker to drive PurgeAnalysis.
+func (r *Repository) ListPurgeableAnalysisIDs(cutoff time.Time) ([]uuid.UUID, error) {
+	query := `SELECT id FROM analyses WHERE archived_at IS NOT NULL AND archived_at < ?`
+	rows, err := r.db.DB.Query(query, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list purgeable analyses: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAnalysisIDs(rows)
+}
+
+// scanAnalysisIDs drains a result set of single id columns into UUIDs.
+func scanAnalysisIDs(rows interface {
+	Next() bool
+	Scan(...interface{}) error
+	Err() error
+}) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for rows.Next() {
+		var idStr string
+		if err := rows.Scan(&idStr); err != nil {
+			return nil, fmt.Errorf("failed to scan analysis id: %w", err)
+		}
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse UUID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate analysis ids: %w", err)
+	}
+	return ids, nil
+}
+
+// writeCompressedReport gzip-compresses report as JSON to path.
+func writeCompressedReport(path string, report *models.AnalysisReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	if err := json.NewEncoder(gz).Encode(report); err != nil {
+		gz.Close()
+		return err
+	}
+
+	// Close explicitly (not deferred): it flushes the gzip trailer, and a
+	// failure here means the file on disk is truncated/corrupt even though
+	// Encode succeeded. Archive must see that error before it deletes the
+	// only other copy of the report from report_json.
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to flush compressed report: %w", err)
+	}
+	return nil
+}
+
+// readCompressedReport reverses writeCompressedReport.
+func readCompressedReport(path string) (*models.AnalysisReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var report models.AnalysisReport
+	if err := json.NewDecoder(gz).Decode(&report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
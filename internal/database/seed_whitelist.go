@@ -1,7 +1,6 @@
 package database
 
 import (
-	"log"
 	"time"
 
 	"github.com/cupax/cupax/internal/models"
@@ -17,11 +16,11 @@ func (r *Repository) SeedDefaultWhitelists() error {
 	}
 
 	if len(existing) > 0 {
-		log.Println("Whitelists already seeded, skipping...")
+		r.logger.Debug("whitelists already seeded, skipping")
 		return nil
 	}
 
-	log.Println("Seeding default whitelists...")
+	r.logger.Info("seeding default whitelists")
 
 	defaultWhitelists := []models.Whitelist{
 		// Windows System Processes
@@ -195,10 +194,10 @@ func (r *Repository) SeedDefaultWhitelists() error {
 
 	for _, wl := range defaultWhitelists {
 		if err := r.CreateWhitelist(&wl); err != nil {
-			log.Printf("Warning: Failed to seed whitelist entry %s: %v", wl.Value, err)
+			r.logger.Warn("failed to seed whitelist entry", "value", wl.Value, "error", err)
 		}
 	}
 
-	log.Printf("Seeded %d default whitelist entries", len(defaultWhitelists))
+	r.logger.Info("seeded default whitelist entries", "count", len(defaultWhitelists))
 	return nil
 }
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -11,19 +12,31 @@ import (
 
 // CreateWhitelist creates a new whitelist entry
 func (r *Repository) CreateWhitelist(wl *models.Whitelist) error {
+	return r.CreateWhitelistContext(context.Background(), wl)
+}
+
+// CreateWhitelistContext is CreateWhitelist with caller-controlled
+// cancellation and deadline.
+func (r *Repository) CreateWhitelistContext(ctx context.Context, wl *models.Whitelist) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := `
-		INSERT INTO whitelists (id, type, value, description, is_regex, enabled, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO whitelists (id, type, value, description, is_regex, is_cidr, enabled, source, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.DB.Exec(
+	_, err := r.db.DB.ExecContext(
+		ctx,
 		query,
 		wl.ID.String(),
 		wl.Type,
 		wl.Value,
 		wl.Description,
 		wl.IsRegex,
+		wl.IsCIDR,
 		wl.Enabled,
+		wl.Source,
 		wl.CreatedAt,
 		wl.UpdatedAt,
 	)
@@ -37,8 +50,17 @@ func (r *Repository) CreateWhitelist(wl *models.Whitelist) error {
 
 // GetWhitelistByID retrieves a whitelist entry by ID
 func (r *Repository) GetWhitelistByID(id uuid.UUID) (*models.Whitelist, error) {
+	return r.GetWhitelistByIDContext(context.Background(), id)
+}
+
+// GetWhitelistByIDContext is GetWhitelistByID with caller-controlled
+// cancellation and deadline.
+func (r *Repository) GetWhitelistByIDContext(ctx context.Context, id uuid.UUID) (*models.Whitelist, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, type, value, description, is_regex, enabled, created_at, updated_at
+		SELECT id, type, value, description, is_regex, is_cidr, enabled, source, created_at, updated_at
 		FROM whitelists
 		WHERE id = ?
 	`
@@ -46,13 +68,15 @@ func (r *Repository) GetWhitelistByID(id uuid.UUID) (*models.Whitelist, error) {
 	var wl models.Whitelist
 	var idStr string
 
-	err := r.db.DB.QueryRow(query, id.String()).Scan(
+	err := r.db.DB.QueryRowContext(ctx, query, id.String()).Scan(
 		&idStr,
 		&wl.Type,
 		&wl.Value,
 		&wl.Description,
 		&wl.IsRegex,
+		&wl.IsCIDR,
 		&wl.Enabled,
+		&wl.Source,
 		&wl.CreatedAt,
 		&wl.UpdatedAt,
 	)
@@ -72,102 +96,114 @@ func (r *Repository) GetWhitelistByID(id uuid.UUID) (*models.Whitelist, error) {
 
 // GetAllWhitelists retrieves all whitelist entries
 func (r *Repository) GetAllWhitelists() ([]models.Whitelist, error) {
+	return r.GetAllWhitelistsContext(context.Background())
+}
+
+// GetAllWhitelistsContext is GetAllWhitelists with caller-controlled
+// cancellation and deadline.
+func (r *Repository) GetAllWhitelistsContext(ctx context.Context) ([]models.Whitelist, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, type, value, description, is_regex, enabled, created_at, updated_at
+		SELECT id, type, value, description, is_regex, is_cidr, enabled, source, created_at, updated_at
 		FROM whitelists
 		ORDER BY type, value
 	`
 
-	rows, err := r.db.DB.Query(query)
+	rows, err := r.db.DB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query whitelists: %w", err)
 	}
 	defer rows.Close()
 
-	var whitelists []models.Whitelist
-	for rows.Next() {
-		var wl models.Whitelist
-		var idStr string
-
-		err := rows.Scan(
-			&idStr,
-			&wl.Type,
-			&wl.Value,
-			&wl.Description,
-			&wl.IsRegex,
-			&wl.Enabled,
-			&wl.CreatedAt,
-			&wl.UpdatedAt,
-		)
-
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan whitelist: %w", err)
-		}
-
-		wl.ID, _ = uuid.Parse(idStr)
-		whitelists = append(whitelists, wl)
-	}
-
-	return whitelists, nil
+	return scanWhitelists(rows)
 }
 
 // GetWhitelistsByType retrieves whitelist entries by type
 func (r *Repository) GetWhitelistsByType(whitelistType models.WhitelistType) ([]models.Whitelist, error) {
+	return r.GetWhitelistsByTypeContext(context.Background(), whitelistType)
+}
+
+// GetWhitelistsByTypeContext is GetWhitelistsByType with caller-controlled
+// cancellation and deadline.
+func (r *Repository) GetWhitelistsByTypeContext(ctx context.Context, whitelistType models.WhitelistType) ([]models.Whitelist, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, type, value, description, is_regex, enabled, created_at, updated_at
+		SELECT id, type, value, description, is_regex, is_cidr, enabled, source, created_at, updated_at
 		FROM whitelists
 		WHERE type = ?
 		ORDER BY value
 	`
 
-	rows, err := r.db.DB.Query(query, whitelistType)
+	rows, err := r.db.DB.QueryContext(ctx, query, whitelistType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query whitelists by type: %w", err)
 	}
 	defer rows.Close()
 
-	var whitelists []models.Whitelist
-	for rows.Next() {
-		var wl models.Whitelist
-		var idStr string
+	return scanWhitelists(rows)
+}
 
-		err := rows.Scan(
-			&idStr,
-			&wl.Type,
-			&wl.Value,
-			&wl.Description,
-			&wl.IsRegex,
-			&wl.Enabled,
-			&wl.CreatedAt,
-			&wl.UpdatedAt,
-		)
+// GetEnabledWhitelists retrieves all enabled whitelist entries
+func (r *Repository) GetEnabledWhitelists() ([]models.Whitelist, error) {
+	return r.GetEnabledWhitelistsContext(context.Background())
+}
 
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan whitelist: %w", err)
-		}
+// GetEnabledWhitelistsContext is GetEnabledWhitelists with caller-controlled
+// cancellation and deadline.
+func (r *Repository) GetEnabledWhitelistsContext(ctx context.Context) ([]models.Whitelist, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
 
-		wl.ID, _ = uuid.Parse(idStr)
-		whitelists = append(whitelists, wl)
+	query := `
+		SELECT id, type, value, description, is_regex, is_cidr, enabled, source, created_at, updated_at
+		FROM whitelists
+		WHERE enabled = 1
+		ORDER BY type, value
+	`
+
+	rows, err := r.db.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query enabled whitelists: %w", err)
 	}
+	defer rows.Close()
 
-	return whitelists, nil
+	return scanWhitelists(rows)
 }
 
-// GetEnabledWhitelists retrieves all enabled whitelist entries
-func (r *Repository) GetEnabledWhitelists() ([]models.Whitelist, error) {
+// GetWhitelistsBySource retrieves whitelist entries pulled from a given feed
+func (r *Repository) GetWhitelistsBySource(source string) ([]models.Whitelist, error) {
+	return r.GetWhitelistsBySourceContext(context.Background(), source)
+}
+
+// GetWhitelistsBySourceContext is GetWhitelistsBySource with
+// caller-controlled cancellation and deadline.
+func (r *Repository) GetWhitelistsBySourceContext(ctx context.Context, source string) ([]models.Whitelist, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, type, value, description, is_regex, enabled, created_at, updated_at
+		SELECT id, type, value, description, is_regex, is_cidr, enabled, source, created_at, updated_at
 		FROM whitelists
-		WHERE enabled = 1
+		WHERE source = ?
 		ORDER BY type, value
 	`
 
-	rows, err := r.db.DB.Query(query)
+	rows, err := r.db.DB.QueryContext(ctx, query, source)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query enabled whitelists: %w", err)
+		return nil, fmt.Errorf("failed to query whitelists by source: %w", err)
 	}
 	defer rows.Close()
 
+	return scanWhitelists(rows)
+}
+
+// scanWhitelists drains a whitelists result set into models.Whitelist
+// values, shared by every whitelist listing query above.
+func scanWhitelists(rows *sql.Rows) ([]models.Whitelist, error) {
 	var whitelists []models.Whitelist
 	for rows.Next() {
 		var wl models.Whitelist
@@ -179,7 +215,9 @@ func (r *Repository) GetEnabledWhitelists() ([]models.Whitelist, error) {
 			&wl.Value,
 			&wl.Description,
 			&wl.IsRegex,
+			&wl.IsCIDR,
 			&wl.Enabled,
+			&wl.Source,
 			&wl.CreatedAt,
 			&wl.UpdatedAt,
 		)
@@ -192,11 +230,71 @@ func (r *Repository) GetEnabledWhitelists() ([]models.Whitelist, error) {
 		whitelists = append(whitelists, wl)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate whitelists: %w", err)
+	}
+
 	return whitelists, nil
 }
 
+// GetWhitelistVersion returns the monotonic version counter maintained by
+// the whitelists table triggers. Callers can cache a compiled view of the
+// whitelists and cheaply detect staleness by comparing this value instead
+// of re-querying and re-parsing every entry on every check.
+func (r *Repository) GetWhitelistVersion() (int64, error) {
+	return r.GetWhitelistVersionContext(context.Background())
+}
+
+// GetWhitelistVersionContext is GetWhitelistVersion with caller-controlled
+// cancellation and deadline.
+func (r *Repository) GetWhitelistVersionContext(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var version int64
+	err := r.db.DB.QueryRowContext(ctx, `SELECT version FROM whitelist_meta WHERE id = 1`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get whitelist version: %w", err)
+	}
+	return version, nil
+}
+
+// DeleteWhitelistsBySource purges every whitelist entry pulled from a given
+// feed, e.g. when the feed is removed or an import is rolled back
+func (r *Repository) DeleteWhitelistsBySource(source string) (int64, error) {
+	return r.DeleteWhitelistsBySourceContext(context.Background(), source)
+}
+
+// DeleteWhitelistsBySourceContext is DeleteWhitelistsBySource with
+// caller-controlled cancellation and deadline.
+func (r *Repository) DeleteWhitelistsBySourceContext(ctx context.Context, source string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	result, err := r.db.DB.ExecContext(ctx, `DELETE FROM whitelists WHERE source = ?`, source)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete whitelists by source: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
 // UpdateWhitelist updates a whitelist entry
 func (r *Repository) UpdateWhitelist(id uuid.UUID, update *models.WhitelistUpdate) error {
+	return r.UpdateWhitelistContext(context.Background(), id, update)
+}
+
+// UpdateWhitelistContext is UpdateWhitelist with caller-controlled
+// cancellation and deadline.
+func (r *Repository) UpdateWhitelistContext(ctx context.Context, id uuid.UUID, update *models.WhitelistUpdate) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	// Build dynamic update query
 	query := "UPDATE whitelists SET updated_at = ?"
 	args := []interface{}{time.Now()}
@@ -216,6 +314,11 @@ func (r *Repository) UpdateWhitelist(id uuid.UUID, update *models.WhitelistUpdat
 		args = append(args, *update.IsRegex)
 	}
 
+	if update.IsCIDR != nil {
+		query += ", is_cidr = ?"
+		args = append(args, *update.IsCIDR)
+	}
+
 	if update.Enabled != nil {
 		query += ", enabled = ?"
 		args = append(args, *update.Enabled)
@@ -224,7 +327,7 @@ func (r *Repository) UpdateWhitelist(id uuid.UUID, update *models.WhitelistUpdat
 	query += " WHERE id = ?"
 	args = append(args, id.String())
 
-	result, err := r.db.DB.Exec(query, args...)
+	result, err := r.db.DB.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update whitelist: %w", err)
 	}
@@ -241,11 +344,80 @@ func (r *Repository) UpdateWhitelist(id uuid.UUID, update *models.WhitelistUpdat
 	return nil
 }
 
+// ImportWhitelists applies a parsed import bundle transactionally: entries
+// are validated and parsed by the caller before this is ever called, so the
+// only failure mode left is a DB error, in which case nothing is applied.
+// In "replace" mode every existing whitelist entry is deleted first; in
+// "merge" mode (the default) entries are upserted by (type, value), the
+// same unique constraint SyncFromFeed relies on. Returns how many entries
+// were applied.
+func (r *Repository) ImportWhitelists(entries []models.Whitelist, mode string) (applied int, err error) {
+	tx, err := r.db.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin import transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if mode == "replace" {
+		if _, err := tx.Exec(`DELETE FROM whitelists`); err != nil {
+			return 0, fmt.Errorf("failed to clear existing whitelists: %w", err)
+		}
+	}
+
+	insert := `
+		INSERT INTO whitelists (id, type, value, description, is_regex, is_cidr, enabled, source, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (type, value) DO UPDATE SET
+			description = excluded.description,
+			is_regex = excluded.is_regex,
+			is_cidr = excluded.is_cidr,
+			enabled = excluded.enabled,
+			updated_at = excluded.updated_at
+	`
+
+	for _, entry := range entries {
+		if entry.ID == uuid.Nil {
+			entry.ID = uuid.New()
+		}
+		if _, err := tx.Exec(
+			insert,
+			entry.ID.String(),
+			entry.Type,
+			entry.Value,
+			entry.Description,
+			entry.IsRegex,
+			entry.IsCIDR,
+			entry.Enabled,
+			entry.Source,
+			entry.CreatedAt,
+			entry.UpdatedAt,
+		); err != nil {
+			return 0, fmt.Errorf("failed to apply entry (%s, %q): %w", entry.Type, entry.Value, err)
+		}
+		applied++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit import transaction: %w", err)
+	}
+
+	return applied, nil
+}
+
 // DeleteWhitelist deletes a whitelist entry
 func (r *Repository) DeleteWhitelist(id uuid.UUID) error {
+	return r.DeleteWhitelistContext(context.Background(), id)
+}
+
+// DeleteWhitelistContext is DeleteWhitelist with caller-controlled
+// cancellation and deadline.
+func (r *Repository) DeleteWhitelistContext(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	query := "DELETE FROM whitelists WHERE id = ?"
 
-	result, err := r.db.DB.Exec(query, id.String())
+	result, err := r.db.DB.ExecContext(ctx, query, id.String())
 	if err != nil {
 		return fmt.Errorf("failed to delete whitelist: %w", err)
 	}
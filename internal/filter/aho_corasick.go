@@ -0,0 +1,95 @@
+package filter
+
+// acNode is one state in an Aho-Corasick trie/automaton.
+type acNode struct {
+	children map[byte]*acNode
+	fail     *acNode
+	terminal bool
+}
+
+// acAutomaton is a compiled Aho-Corasick automaton over a set of case-folded
+// literal patterns. A single pass over the input finds every pattern that
+// occurs in it, replacing what used to be one strings.Contains scan per
+// whitelist entry.
+type acAutomaton struct {
+	root *acNode
+}
+
+// newACAutomaton builds an automaton from already case-folded patterns.
+func newACAutomaton(patterns []string) *acAutomaton {
+	root := &acNode{children: make(map[byte]*acNode)}
+
+	for _, pattern := range patterns {
+		node := root
+		for i := 0; i < len(pattern); i++ {
+			c := pattern[i]
+			next, ok := node.children[c]
+			if !ok {
+				next = &acNode{children: make(map[byte]*acNode)}
+				node.children[c] = next
+			}
+			node = next
+		}
+		node.terminal = true
+	}
+
+	// Breadth-first build of the fail links: child.fail is the longest
+	// proper suffix of child's path that is also a path from root.
+	queue := make([]*acNode, 0, len(root.children))
+	for _, child := range root.children {
+		child.fail = root
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for c, child := range node.children {
+			queue = append(queue, child)
+
+			failNode := node.fail
+			for failNode != nil {
+				if next, ok := failNode.children[c]; ok {
+					child.fail = next
+					break
+				}
+				failNode = failNode.fail
+			}
+			if child.fail == nil {
+				child.fail = root
+			}
+			if child.fail.terminal {
+				child.terminal = true
+			}
+		}
+	}
+
+	return &acAutomaton{root: root}
+}
+
+// match reports whether any compiled pattern occurs anywhere in value.
+// value must be case-folded the same way the patterns were at build time.
+func (a *acAutomaton) match(value string) bool {
+	if a == nil || a.root == nil {
+		return false
+	}
+
+	node := a.root
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		for node != a.root {
+			if _, ok := node.children[c]; ok {
+				break
+			}
+			node = node.fail
+		}
+		if next, ok := node.children[c]; ok {
+			node = next
+		}
+		if node.terminal {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,219 @@
+package filter
+
+import (
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/cupax/cupax/internal/database"
+	"github.com/cupax/cupax/internal/models"
+)
+
+// IOCMatcher annotates an already-whitelist-filtered AnalysisReport with
+// blacklist/IOC hits and an aggregate Verdict. It must run after
+// WhitelistFilter.FilterReport: whitelisting is a defensive allow, IOC
+// matching is the offensive complement.
+type IOCMatcher struct {
+	repo *database.Repository
+	// mu guards blacklists: LoadBlacklists builds a fresh map and swaps it
+	// in under the write lock, while matches reads it under the read lock
+	// from whatever worker goroutine is handling a given analysis.
+	mu         sync.RWMutex
+	blacklists map[models.BlacklistType][]models.Blacklist
+}
+
+// NewIOCMatcher creates a new IOC matcher
+func NewIOCMatcher(repo *database.Repository) *IOCMatcher {
+	return &IOCMatcher{
+		repo:       repo,
+		blacklists: make(map[models.BlacklistType][]models.Blacklist),
+	}
+}
+
+// LoadBlacklists loads enabled blacklist/IOC entries from the database
+func (m *IOCMatcher) LoadBlacklists() error {
+	blacklists, err := m.repo.GetEnabledBlacklists()
+	if err != nil {
+		return err
+	}
+
+	byType := make(map[models.BlacklistType][]models.Blacklist)
+	for _, bl := range blacklists {
+		byType[bl.Type] = append(byType[bl.Type], bl)
+	}
+
+	m.mu.Lock()
+	m.blacklists = byType
+	m.mu.Unlock()
+
+	log.Printf("Loaded %d enabled blacklist entries", len(blacklists))
+	return nil
+}
+
+// correspondingWhitelistType maps a BlacklistType to the WhitelistType that
+// could conflict with it. mutex has no whitelist equivalent.
+func correspondingWhitelistType(t models.BlacklistType) (models.WhitelistType, bool) {
+	switch t {
+	case models.BlacklistTypeSHA256:
+		return models.WhitelistTypeHash, true
+	case models.BlacklistTypeProcess:
+		return models.WhitelistTypeProcess, true
+	case models.BlacklistTypeDomain:
+		return models.WhitelistTypeDomain, true
+	case models.BlacklistTypeIP:
+		return models.WhitelistTypeIP, true
+	case models.BlacklistTypeRegistry:
+		return models.WhitelistTypeRegistry, true
+	default:
+		return "", false
+	}
+}
+
+// matches checks a value against every enabled blacklist entry of a type and
+// resolves whitelist/blacklist conflicts on the same value: whichever rule
+// has the newer updated_at wins, and the conflict is logged either way.
+func (m *IOCMatcher) matches(value string, blacklistType models.BlacklistType) []models.IOCMatch {
+	if value == "" {
+		return nil
+	}
+
+	m.mu.RLock()
+	entries, exists := m.blacklists[blacklistType]
+	m.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	var hits []models.IOCMatch
+	for _, entry := range entries {
+		if !entryMatches(entry, value) {
+			continue
+		}
+
+		if wlType, ok := correspondingWhitelistType(blacklistType); ok {
+			wl, err := m.repo.GetWhitelistByTypeValue(wlType, value)
+			if err != nil {
+				log.Printf("IOC conflict check failed for %s %q: %v", blacklistType, value, err)
+			} else if wl != nil {
+				if !entry.UpdatedAt.After(wl.UpdatedAt) {
+					log.Printf("IOC conflict on %s %q: whitelist entry (updated %s) wins over blacklist entry (updated %s)",
+						blacklistType, value, wl.UpdatedAt, entry.UpdatedAt)
+					continue
+				}
+				log.Printf("IOC conflict on %s %q: blacklist entry (updated %s) wins over whitelist entry (updated %s)",
+					blacklistType, value, entry.UpdatedAt, wl.UpdatedAt)
+			}
+		}
+
+		hits = append(hits, models.IOCMatch{
+			Type:        string(blacklistType),
+			Value:       value,
+			Description: entry.Description,
+			Severity:    entry.Severity,
+		})
+	}
+
+	return hits
+}
+
+func entryMatches(entry models.Blacklist, value string) bool {
+	switch {
+	case entry.IsRegex:
+		matched, err := regexp.MatchString(entry.Value, value)
+		if err != nil {
+			log.Printf("Invalid regex pattern %s: %v", entry.Value, err)
+			return false
+		}
+		return matched
+	case entry.Type == models.BlacklistTypeIP && entry.IsCIDR:
+		_, network, err := net.ParseCIDR(entry.Value)
+		if err != nil {
+			log.Printf("Invalid CIDR %s: %v", entry.Value, err)
+			return false
+		}
+		ip := net.ParseIP(value)
+		return ip != nil && network.Contains(ip)
+	default:
+		return strings.Contains(strings.ToLower(value), strings.ToLower(entry.Value))
+	}
+}
+
+// MatchReport scans an analysis report for blacklisted indicators and
+// attaches the hits plus an aggregate Verdict. Call after
+// WhitelistFilter.FilterReport. If the caller also runs rules.Engine.Score
+// afterward, its Verdict (folding in this one's IOCScore) wins.
+func (m *IOCMatcher) MatchReport(report *models.AnalysisReport) *models.AnalysisReport {
+	if report == nil {
+		return report
+	}
+
+	if err := m.LoadBlacklists(); err != nil {
+		log.Printf("Warning: Failed to reload blacklists: %v", err)
+	}
+
+	var hits []models.IOCMatch
+
+	for _, event := range report.ProcessActivity {
+		hits = append(hits, m.matches(event.ProcessName, models.BlacklistTypeProcess)...)
+	}
+
+	for _, event := range report.FileSystem {
+		hits = append(hits, m.matches(event.ProcessName, models.BlacklistTypeProcess)...)
+		if event.HashType == "SHA256" || event.HashType == "sha256" {
+			hits = append(hits, m.matches(event.Hash, models.BlacklistTypeSHA256)...)
+		}
+	}
+
+	for _, event := range report.Registry {
+		hits = append(hits, m.matches(event.ProcessName, models.BlacklistTypeProcess)...)
+		hits = append(hits, m.matches(event.Path, models.BlacklistTypeRegistry)...)
+	}
+
+	for _, event := range report.Network {
+		host := event.RemoteAddr
+		if idx := strings.Index(host, ":"); idx != -1 {
+			host = host[:idx]
+		}
+		hits = append(hits, m.matches(host, models.BlacklistTypeIP)...)
+		hits = append(hits, m.matches(host, models.BlacklistTypeDomain)...)
+	}
+
+	for _, host := range report.UniqueHosts {
+		hits = append(hits, m.matches(host, models.BlacklistTypeIP)...)
+		hits = append(hits, m.matches(host, models.BlacklistTypeDomain)...)
+	}
+
+	report.MatchedIOCs = hits
+	report.IOCScore = aggregateScore(hits)
+	report.Verdict = verdictForScore(report.IOCScore)
+
+	log.Printf("IOC matching applied: %d hits, score %d, verdict %s", len(hits), report.IOCScore, report.Verdict)
+
+	return report
+}
+
+// aggregateScore sums the severity of every hit, capped at 100
+func aggregateScore(hits []models.IOCMatch) int {
+	score := 0
+	for _, hit := range hits {
+		score += hit.Severity
+	}
+	if score > 100 {
+		score = 100
+	}
+	return score
+}
+
+// verdictForScore buckets an aggregate IOC score into a Verdict
+func verdictForScore(score int) models.Verdict {
+	switch {
+	case score <= 0:
+		return models.VerdictClean
+	case score < 50:
+		return models.VerdictSuspicious
+	default:
+		return models.VerdictMalicious
+	}
+}
@@ -0,0 +1,151 @@
+package filter
+
+import (
+	"net"
+	"strings"
+)
+
+// cidrNode is one bit of a binary radix trie over IP prefixes.
+type cidrNode struct {
+	children [2]*cidrNode
+	terminal bool
+}
+
+// cidrTrie indexes whitelist IP networks for O(prefix length) containment
+// checks instead of a linear scan of net.ParseCIDR + Contains calls.
+// IPv4 and IPv6 networks are kept in separate tries since an IPv4 /24 and
+// an IPv6 /24 address completely different bit ranges.
+type cidrTrie struct {
+	v4 *cidrNode
+	v6 *cidrNode
+}
+
+// newCIDRTrie builds a trie from a set of already-parsed networks.
+func newCIDRTrie(networks []*net.IPNet) *cidrTrie {
+	t := &cidrTrie{}
+	for _, network := range networks {
+		ones, bits := network.Mask.Size()
+		if bits == 32 {
+			if t.v4 == nil {
+				t.v4 = &cidrNode{}
+			}
+			insertCIDR(t.v4, network.IP.To4(), ones)
+		} else {
+			if t.v6 == nil {
+				t.v6 = &cidrNode{}
+			}
+			insertCIDR(t.v6, network.IP.To16(), ones)
+		}
+	}
+	return t
+}
+
+func insertCIDR(root *cidrNode, ip net.IP, prefixLen int) {
+	node := root
+	for i := 0; i < prefixLen; i++ {
+		bit := ipBit(ip, i)
+		if node.children[bit] == nil {
+			node.children[bit] = &cidrNode{}
+		}
+		node = node.children[bit]
+	}
+	node.terminal = true
+}
+
+func ipBit(ip net.IP, i int) int {
+	byteIdx := i / 8
+	bitIdx := 7 - uint(i%8)
+	return int((ip[byteIdx] >> bitIdx) & 1)
+}
+
+// contains reports whether ip falls inside any stored network.
+func (t *cidrTrie) contains(ip net.IP) bool {
+	if t == nil {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return containsIP(t.v4, ip4)
+	}
+	return containsIP(t.v6, ip.To16())
+}
+
+func containsIP(root *cidrNode, ip net.IP) bool {
+	if root == nil || ip == nil {
+		return false
+	}
+
+	node := root
+	for i := 0; i < len(ip)*8; i++ {
+		if node.terminal {
+			return true
+		}
+		next := node.children[ipBit(ip, i)]
+		if next == nil {
+			return false
+		}
+		node = next
+	}
+	return node.terminal
+}
+
+// domainNode is one DNS label in a reversed-label domain trie.
+type domainNode struct {
+	children map[string]*domainNode
+	terminal bool
+}
+
+// domainTrie indexes whitelist domain patterns by reversed DNS label so
+// that "evil.example.com" matches a stored "example.com" entry on a label
+// boundary, without the false positives a plain substring scan allows
+// (e.g. "notexample.com" must not match "example.com").
+type domainTrie struct {
+	root *domainNode
+}
+
+func newDomainTrie(patterns []string) *domainTrie {
+	root := &domainNode{children: make(map[string]*domainNode)}
+	for _, pattern := range patterns {
+		node := root
+		for _, label := range reversedLabels(pattern) {
+			next, ok := node.children[label]
+			if !ok {
+				next = &domainNode{children: make(map[string]*domainNode)}
+				node.children[label] = next
+			}
+			node = next
+		}
+		node.terminal = true
+	}
+	return &domainTrie{root: root}
+}
+
+// reversedLabels splits a lower-cased domain into its DNS labels, outermost
+// (TLD) first, e.g. "evil.example.com" -> ["com", "example", "evil"].
+func reversedLabels(domain string) []string {
+	labels := strings.Split(strings.ToLower(domain), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return labels
+}
+
+// matchSuffix reports whether value has any whitelisted domain as a
+// label-aligned suffix.
+func (t *domainTrie) matchSuffix(value string) bool {
+	if t == nil || t.root == nil {
+		return false
+	}
+
+	node := t.root
+	for _, label := range reversedLabels(value) {
+		next, ok := node.children[label]
+		if !ok {
+			return false
+		}
+		node = next
+		if node.terminal {
+			return true
+		}
+	}
+	return false
+}
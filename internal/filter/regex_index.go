@@ -0,0 +1,65 @@
+package filter
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// regexIndex holds a set of whitelist regex entries pre-compiled once at
+// load time instead of via a regexp.MatchString call (which recompiles the
+// pattern) on every check. Where the patterns allow it, they are combined
+// into a single alternation with named subexpressions identifying which
+// entry matched, so a value is scanned once instead of once per pattern.
+type regexIndex struct {
+	combined *regexp.Regexp
+	fallback []*regexp.Regexp
+}
+
+// newRegexIndex compiles patterns, logging and skipping any that don't
+// compile on their own (mirroring the invalid-pattern handling the
+// per-call matcher used to do).
+func newRegexIndex(patterns []string) *regexIndex {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	parts := make([]string, 0, len(patterns))
+
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("Invalid regex pattern %s: %v", pattern, err)
+			continue
+		}
+		compiled = append(compiled, re)
+		parts = append(parts, fmt.Sprintf("(?P<e%d>%s)", i, pattern))
+	}
+
+	if len(parts) == 0 {
+		return &regexIndex{}
+	}
+
+	if combined, err := regexp.Compile(strings.Join(parts, "|")); err == nil {
+		return &regexIndex{combined: combined}
+	}
+
+	// Patterns don't compose into one alternation (e.g. colliding named
+	// groups) -- fall back to matching each compiled regex individually.
+	// Still avoids recompiling on every call.
+	return &regexIndex{fallback: compiled}
+}
+
+// match reports whether value matches any of the compiled patterns.
+func (r *regexIndex) match(value string) bool {
+	if r == nil {
+		return false
+	}
+	if r.combined != nil {
+		return r.combined.MatchString(value)
+	}
+	for _, re := range r.fallback {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,75 +2,176 @@ package filter
 
 import (
 	"log"
-	"regexp"
+	"net"
 	"strings"
+	"sync"
 
 	"github.com/cupax/cupax/internal/database"
+	"github.com/cupax/cupax/internal/metrics"
 	"github.com/cupax/cupax/internal/models"
 )
 
+// whitelistIndex is the compiled, read-only view of every enabled
+// whitelist entry of a single type. It replaces a linear []models.Whitelist
+// scanned (and, for regexes, recompiled) on every check: literals go
+// through an Aho-Corasick automaton, domains through a suffix trie, IP
+// CIDRs through a radix trie, and regexes are pre-compiled once.
+type whitelistIndex struct {
+	literals *acAutomaton // case-folded substrings (process, registry, hash, non-CIDR ip)
+	domains  *domainTrie  // type=domain, label-aligned suffix match
+	cidrs    *cidrTrie    // type=ip, IsCIDR entries
+	regexes  *regexIndex  // IsRegex entries of any type
+}
+
+// buildWhitelistIndex compiles every enabled entry of one type into a
+// whitelistIndex.
+func buildWhitelistIndex(whitelistType models.WhitelistType, entries []models.Whitelist) *whitelistIndex {
+	var literals, domains, regexPatterns []string
+	var networks []*net.IPNet
+
+	for _, entry := range entries {
+		switch {
+		case entry.IsRegex:
+			regexPatterns = append(regexPatterns, entry.Value)
+		case whitelistType == models.WhitelistTypeIP && entry.IsCIDR:
+			_, network, err := net.ParseCIDR(entry.Value)
+			if err != nil {
+				log.Printf("Invalid CIDR %s: %v", entry.Value, err)
+				continue
+			}
+			networks = append(networks, network)
+		case whitelistType == models.WhitelistTypeDomain:
+			domains = append(domains, entry.Value)
+		default:
+			literals = append(literals, strings.ToLower(entry.Value))
+		}
+	}
+
+	idx := &whitelistIndex{}
+	if len(literals) > 0 {
+		idx.literals = newACAutomaton(literals)
+	}
+	if len(domains) > 0 {
+		idx.domains = newDomainTrie(domains)
+	}
+	if len(networks) > 0 {
+		idx.cidrs = newCIDRTrie(networks)
+	}
+	if len(regexPatterns) > 0 {
+		idx.regexes = newRegexIndex(regexPatterns)
+	}
+	return idx
+}
+
+// match reports whether value matches any entry compiled into the index.
+func (idx *whitelistIndex) match(value string) bool {
+	if idx == nil {
+		return false
+	}
+	if idx.literals != nil && idx.literals.match(strings.ToLower(value)) {
+		return true
+	}
+	if idx.domains != nil && idx.domains.matchSuffix(value) {
+		return true
+	}
+	if idx.cidrs != nil {
+		if ip := net.ParseIP(value); ip != nil && idx.cidrs.contains(ip) {
+			return true
+		}
+	}
+	if idx.regexes != nil && idx.regexes.match(value) {
+		return true
+	}
+	return false
+}
+
 // WhitelistFilter handles filtering of analysis reports based on whitelists
 type WhitelistFilter struct {
-	repo       *database.Repository
-	whitelists map[models.WhitelistType][]models.Whitelist
+	repo    *database.Repository
+	metrics *metrics.Metrics
+	// mu guards indexes and version: LoadWhitelists rebuilds them into
+	// fresh local values and swaps them in under the write lock, while
+	// isWhitelisted/reloadIfStale read them under the read lock from
+	// whatever worker goroutine is handling a given analysis.
+	mu      sync.RWMutex
+	indexes map[models.WhitelistType]*whitelistIndex
+	// version is the whitelists table version counter as of the last
+	// LoadWhitelists call, used by reloadIfStale to skip rebuilding the
+	// index when nothing has changed.
+	version int64
 }
 
 // NewWhitelistFilter creates a new whitelist filter
-func NewWhitelistFilter(repo *database.Repository) *WhitelistFilter {
+func NewWhitelistFilter(repo *database.Repository, m *metrics.Metrics) *WhitelistFilter {
 	return &WhitelistFilter{
-		repo:       repo,
-		whitelists: make(map[models.WhitelistType][]models.Whitelist),
+		repo:    repo,
+		metrics: m,
+		indexes: make(map[models.WhitelistType]*whitelistIndex),
+		version: -1,
 	}
 }
 
-// LoadWhitelists loads enabled whitelists from database
+// LoadWhitelists loads enabled whitelists from the database and compiles
+// them into per-type indexes.
 func (f *WhitelistFilter) LoadWhitelists() error {
 	whitelists, err := f.repo.GetEnabledWhitelists()
 	if err != nil {
 		return err
 	}
 
-	// Organize by type
-	f.whitelists = make(map[models.WhitelistType][]models.Whitelist)
+	byType := make(map[models.WhitelistType][]models.Whitelist)
 	for _, wl := range whitelists {
-		f.whitelists[wl.Type] = append(f.whitelists[wl.Type], wl)
+		byType[wl.Type] = append(byType[wl.Type], wl)
+	}
+
+	indexes := make(map[models.WhitelistType]*whitelistIndex, len(byType))
+	for t, entries := range byType {
+		indexes[t] = buildWhitelistIndex(t, entries)
 	}
 
+	v, verr := f.repo.GetWhitelistVersion()
+	if verr != nil {
+		log.Printf("Warning: failed to read whitelist version: %v", verr)
+	}
+
+	f.mu.Lock()
+	f.indexes = indexes
+	if verr == nil {
+		f.version = v
+	}
+	f.mu.Unlock()
+
 	log.Printf("Loaded %d enabled whitelist entries", len(whitelists))
 	return nil
 }
 
+// reloadIfStale reloads and recompiles the index only when the whitelists
+// table version counter has moved since the last load, so repeated calls
+// (e.g. once per analysis report) don't pay for a reload, re-parse and
+// re-compile when the whitelists haven't changed.
+func (f *WhitelistFilter) reloadIfStale() error {
+	version, err := f.repo.GetWhitelistVersion()
+	if err != nil {
+		return err
+	}
+	f.mu.RLock()
+	current := f.version
+	f.mu.RUnlock()
+	if version == current {
+		return nil
+	}
+	return f.LoadWhitelists()
+}
+
 // isWhitelisted checks if a value matches any whitelist entry of a given type
 func (f *WhitelistFilter) isWhitelisted(value string, whitelistType models.WhitelistType) bool {
 	if value == "" {
 		return false
 	}
-
-	entries, exists := f.whitelists[whitelistType]
-	if !exists {
-		return false
-	}
-
-	for _, entry := range entries {
-		if entry.IsRegex {
-			// Use regex matching
-			matched, err := regexp.MatchString(entry.Value, value)
-			if err != nil {
-				log.Printf("Invalid regex pattern %s: %v", entry.Value, err)
-				continue
-			}
-			if matched {
-				return true
-			}
-		} else {
-			// Case-insensitive substring matching
-			if strings.Contains(strings.ToLower(value), strings.ToLower(entry.Value)) {
-				return true
-			}
-		}
-	}
-
-	return false
+	f.mu.RLock()
+	idx := f.indexes[whitelistType]
+	f.mu.RUnlock()
+	return idx.match(value)
 }
 
 // FilterReport filters an analysis report based on whitelists
@@ -79,8 +180,9 @@ func (f *WhitelistFilter) FilterReport(report *models.AnalysisReport) *models.An
 		return report
 	}
 
-	// Reload whitelists to get latest changes
-	if err := f.LoadWhitelists(); err != nil {
+	// Reload the compiled index only if the whitelists table has changed
+	// since the last load.
+	if err := f.reloadIfStale(); err != nil {
 		log.Printf("Warning: Failed to reload whitelists: %v", err)
 	}
 
@@ -118,6 +220,12 @@ func (f *WhitelistFilter) FilterReport(report *models.AnalysisReport) *models.An
 		beforeCounts["network"], afterCounts["network"],
 		beforeCounts["hosts"], afterCounts["hosts"])
 
+	if f.metrics != nil {
+		for eventType, before := range beforeCounts {
+			f.metrics.AddWhitelistMatches(eventType, before-afterCounts[eventType])
+		}
+	}
+
 	return report
 }
 
@@ -0,0 +1,26 @@
+// Package logging builds the hclog.Logger shared by cupax's server
+// components, configured from the server's --log-format/--log-level flags.
+package logging
+
+import (
+	"os"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// New creates the root logger for the server. format is "text" (default,
+// human-readable) or "json" (structured, for centralized aggregation via
+// ELK/Loki). An empty or unrecognized level falls back to info.
+func New(format, level string) hclog.Logger {
+	lvl := hclog.LevelFromString(level)
+	if lvl == hclog.NoLevel {
+		lvl = hclog.Info
+	}
+
+	return hclog.New(&hclog.LoggerOptions{
+		Name:       "cupax",
+		Level:      lvl,
+		Output:     os.Stderr,
+		JSONFormat: format == "json",
+	})
+}
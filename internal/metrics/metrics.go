@@ -0,0 +1,256 @@
+// Package metrics gives operators a Prometheus surface over otherwise
+// invisible pipeline behavior: upload volume, per-phase analysis latency,
+// how many samples are sitting in each status, whitelist hit rates, agent
+// pool health, VM rollback/health-check failures, queue depth, DB query
+// timing, and total sample bytes ingested. Every producer (the upload
+// handler, the analyzer, the whitelist filter, the agent pool, the queue,
+// the repository) is handed a *Metrics at construction time and records to
+// it directly, the same way those packages are handed a shared
+// *database.Repository or storage.SampleStore.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds every collector cupax registers, plus the registry they're
+// registered against. It is safe for concurrent use; all fields are
+// Prometheus collectors, which are themselves concurrency-safe.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	UploadsTotal      *prometheus.CounterVec
+	AnalysisDuration  *prometheus.HistogramVec
+	AnalysisStatus    *prometheus.GaugeVec
+	WhitelistMatches  *prometheus.CounterVec
+	AgentHealth       *prometheus.GaugeVec
+	SamplesBytesTotal prometheus.Counter
+
+	AnalysesTotal            *prometheus.CounterVec
+	VMRollbackDuration       prometheus.Histogram
+	VMRollbackFailures       prometheus.Counter
+	VMCleanupDuration        prometheus.Histogram
+	VMCleanupFailures        prometheus.Counter
+	AgentHealthCheckFailures prometheus.Counter
+	QueueDepth               prometheus.Gauge
+	DBQueryDuration          *prometheus.HistogramVec
+}
+
+// New creates a fresh registry under namespace/subsystem and registers all
+// cupax collectors plus the standard Go/process collectors. namespace
+// defaults to "cupax" and subsystem is left blank if not given.
+func New(namespace, subsystem string) *Metrics {
+	if namespace == "" {
+		namespace = "cupax"
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector())
+	reg.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	factory := promauto.With(reg)
+
+	return &Metrics{
+		registry: reg,
+
+		UploadsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "uploads_total",
+			Help:      "Total number of sample uploads, by outcome.",
+		}, []string{"result"}),
+
+		AnalysisDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "analysis_duration_seconds",
+			Help:      "Time spent per analysis phase.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"phase", "agent"}),
+
+		AnalysisStatus: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "analysis_status",
+			Help:      "Number of analyses currently in each status.",
+		}, []string{"status"}),
+
+		WhitelistMatches: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "whitelist_matches_total",
+			Help:      "Total report entries dropped by the whitelist filter, by event type.",
+		}, []string{"type"}),
+
+		AgentHealth: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "agent_health",
+			Help:      "Whether a sandbox agent's last health check passed (1) or failed (0).",
+		}, []string{"agent"}),
+
+		SamplesBytesTotal: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "samples_bytes_total",
+			Help:      "Total bytes of samples accepted for analysis.",
+		}),
+
+		AnalysesTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "analyses_total",
+			Help:      "Total analysis runs that have reached a terminal status.",
+		}, []string{"status"}),
+
+		VMRollbackDuration: factory.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "vm_rollback_duration_seconds",
+			Help:      "Time spent restoring the sandbox backend's guest to a clean state before analysis.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+
+		VMRollbackFailures: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "vm_rollback_failures_total",
+			Help:      "Total failures restoring the sandbox backend's guest to a clean state.",
+		}),
+
+		VMCleanupDuration: factory.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "vm_cleanup_duration_seconds",
+			Help:      "Time spent shutting down the sandbox backend's guest after analysis.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+
+		VMCleanupFailures: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "vm_cleanup_failures_total",
+			Help:      "Total failures shutting down the sandbox backend's guest after analysis. Distinct from vm_rollback_failures_total: a post-analysis cleanup failure is lower urgency than a pre-analysis rollback failure blocking the next analysis.",
+		}),
+
+		AgentHealthCheckFailures: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "agent_health_check_failures_total",
+			Help:      "Total sandbox agent health checks that failed, across all agents.",
+		}),
+
+		QueueDepth: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "queue_depth",
+			Help:      "Number of analysis tasks currently queued, including in-flight retries.",
+		}),
+
+		DBQueryDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "db_query_duration_seconds",
+			Help:      "Time spent per repository query.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"query"}),
+	}
+}
+
+// Handler returns the /metrics HTTP handler for this registry.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveDuration records d against phase/agent in AnalysisDuration. agent
+// is left blank for phases that aren't tied to a specific sandbox agent
+// (e.g. "queue", "persist").
+func (m *Metrics) ObserveDuration(phase, agent string, d time.Duration) {
+	m.AnalysisDuration.WithLabelValues(phase, agent).Observe(d.Seconds())
+}
+
+// IncUpload increments UploadsTotal for result.
+func (m *Metrics) IncUpload(result string) {
+	m.UploadsTotal.WithLabelValues(result).Inc()
+}
+
+// AddAnalysisStatus adjusts the in-flight count for status by delta, e.g.
+// +1 when an analysis enters a status and -1 when it leaves it.
+func (m *Metrics) AddAnalysisStatus(status string, delta float64) {
+	m.AnalysisStatus.WithLabelValues(status).Add(delta)
+}
+
+// AddWhitelistMatches increments WhitelistMatches for eventType by count.
+func (m *Metrics) AddWhitelistMatches(eventType string, count int) {
+	if count <= 0 {
+		return
+	}
+	m.WhitelistMatches.WithLabelValues(eventType).Add(float64(count))
+}
+
+// SetAgentHealth records agent's latest health check result.
+func (m *Metrics) SetAgentHealth(agent string, healthy bool) {
+	value := 0.0
+	if healthy {
+		value = 1.0
+	}
+	m.AgentHealth.WithLabelValues(agent).Set(value)
+}
+
+// AddSampleBytes adds n to SamplesBytesTotal.
+func (m *Metrics) AddSampleBytes(n int64) {
+	if n <= 0 {
+		return
+	}
+	m.SamplesBytesTotal.Add(float64(n))
+}
+
+// IncAnalysesTotal increments AnalysesTotal for status. Analyzer.Analyze
+// calls it each time an agent-backed run finishes (completed or error), so
+// a retried or rejudged analysis is counted once per run.
+func (m *Metrics) IncAnalysesTotal(status string) {
+	m.AnalysesTotal.WithLabelValues(status).Inc()
+}
+
+// ObserveVMRollback records d against VMRollbackDuration.
+func (m *Metrics) ObserveVMRollback(d time.Duration) {
+	m.VMRollbackDuration.Observe(d.Seconds())
+}
+
+// IncVMRollbackFailure increments VMRollbackFailures.
+func (m *Metrics) IncVMRollbackFailure() {
+	m.VMRollbackFailures.Inc()
+}
+
+// ObserveVMCleanup records d against VMCleanupDuration. Called from
+// analyzer.Analyzer.cleanupVM, which runs after every analysis that has a
+// sandbox backend.
+func (m *Metrics) ObserveVMCleanup(d time.Duration) {
+	m.VMCleanupDuration.Observe(d.Seconds())
+}
+
+// IncVMCleanupFailure increments VMCleanupFailures.
+func (m *Metrics) IncVMCleanupFailure() {
+	m.VMCleanupFailures.Inc()
+}
+
+// IncAgentHealthCheckFailure increments AgentHealthCheckFailures.
+func (m *Metrics) IncAgentHealthCheckFailure() {
+	m.AgentHealthCheckFailures.Inc()
+}
+
+// SetQueueDepth records the queue's current depth.
+func (m *Metrics) SetQueueDepth(depth int) {
+	m.QueueDepth.Set(float64(depth))
+}
+
+// ObserveDBQuery records d against DBQueryDuration for query.
+func (m *Metrics) ObserveDBQuery(query string, d time.Duration) {
+	m.DBQueryDuration.WithLabelValues(query).Observe(d.Seconds())
+}
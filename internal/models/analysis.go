@@ -10,6 +10,7 @@ import (
 type AnalysisStatus string
 
 const (
+	StatusQueued    AnalysisStatus = "queued"
 	StatusRunning   AnalysisStatus = "running"
 	StatusCompleted AnalysisStatus = "completed"
 	StatusError     AnalysisStatus = "error"
@@ -20,11 +21,80 @@ type Analysis struct {
 	ID             uuid.UUID       `json:"id"`
 	Filename       string          `json:"filename"`
 	FileHashSHA256 string          `json:"file_hash_sha256"`
+	// SampleURI locates the uploaded sample in whatever SampleStore backend
+	// is configured (e.g. file:///data/samples/<hash>.exe or
+	// s3://bucket/<hash>.exe) and replaces reconstructing a local path from
+	// FileHashSHA256 at rejudge time.
+	SampleURI      string          `json:"sample_uri"`
 	Status         AnalysisStatus  `json:"status"`
 	SubmittedAt    time.Time       `json:"submitted_at"`
 	CompletedAt    *time.Time      `json:"completed_at,omitempty"`
 	ReportJSON     *AnalysisReport `json:"report_json,omitempty"`
 	ErrorMessage   *string         `json:"error_message,omitempty"`
+	// ArchivedAt is set once the retention worker (or an explicit
+	// Repository.Archive call) has moved this analysis's report out of the
+	// hot table and into a compressed file at ArchivePath. Nil means the
+	// record is live and ReportJSON, if any, is still in the database.
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	ArchivePath string     `json:"archive_path,omitempty"`
+}
+
+// IsArchived reports whether this analysis's report has been moved to
+// cold storage.
+func (a *Analysis) IsArchived() bool {
+	return a.ArchivedAt != nil
+}
+
+// AnalysisSummary is the list-view projection of Analysis: every field a
+// listing needs to render a row, and nothing that requires decoding
+// report_json. Handlers build these from a ListAnalyses page fetched with
+// IncludeReport=false so listing analyses never pays the JSON-unmarshal
+// cost GetAnalysisByID does.
+type AnalysisSummary struct {
+	ID             uuid.UUID      `json:"id"`
+	Filename       string         `json:"filename"`
+	FileHashSHA256 string         `json:"file_hash_sha256"`
+	Status         AnalysisStatus `json:"status"`
+	SubmittedAt    time.Time      `json:"submitted_at"`
+	CompletedAt    *time.Time     `json:"completed_at,omitempty"`
+	ArchivedAt     *time.Time     `json:"archived_at,omitempty"`
+}
+
+// AnalysisCursor is a keyset pagination position: the (submitted_at, id) of
+// the last row returned on the previous page. Paired with the repository's
+// submitted_at DESC, id DESC ordering, it lets ListAnalyses resume a listing
+// without an OFFSET scan and without skipping/duplicating rows inserted
+// between pages.
+type AnalysisCursor struct {
+	SubmittedAt time.Time `json:"submitted_at"`
+	ID          uuid.UUID `json:"id"`
+}
+
+// ListOptions filters and paginates a Repository.ListAnalyses call.
+type ListOptions struct {
+	// After, if set, restricts results to rows strictly after this keyset
+	// position (i.e. the next page following it).
+	After *AnalysisCursor
+
+	// PageSize caps the number of rows returned; ListAnalyses applies
+	// DefaultAnalysisPageSize if it is <= 0.
+	PageSize int
+
+	// IncludeReport, when true, also decodes report_json for each row.
+	// Defaults to false so listing never pays the JSON-decode cost.
+	IncludeReport bool
+
+	Status          *AnalysisStatus
+	FilenameLike    string
+	HashPrefix      string
+	SubmittedAfter  *time.Time
+	SubmittedBefore *time.Time
+}
+
+// Page is a single page of a keyset-paginated listing.
+type Page[T any] struct {
+	Items      []T             `json:"items"`
+	NextCursor *AnalysisCursor `json:"next_cursor,omitempty"`
 }
 
 // AnalysisReport contains the parsed results from Noriben
@@ -35,6 +105,35 @@ type AnalysisReport struct {
 	Registry        []RegistryEvent   `json:"registry"`
 	Network         []NetworkEvent    `json:"network"`
 	UniqueHosts     []string          `json:"unique_hosts"`
+
+	// MatchedIOCs and IOCScore are populated by filter.IOCMatcher after
+	// whitelist filtering has run.
+	MatchedIOCs []IOCMatch `json:"matched_iocs,omitempty"`
+	IOCScore    int        `json:"ioc_score,omitempty"`
+
+	// Score, Verdict, and Techniques are populated by rules.Engine, which
+	// runs last and folds IOCScore into Score so a single aggregate
+	// verdict reflects both the blacklist and rule-pack signals.
+	Score      int      `json:"score,omitempty"`
+	Verdict    Verdict  `json:"verdict,omitempty"`
+	Techniques []string `json:"techniques,omitempty"`
+}
+
+// Verdict summarizes the aggregate IOC score for a report
+type Verdict string
+
+const (
+	VerdictClean      Verdict = "clean"
+	VerdictSuspicious Verdict = "suspicious"
+	VerdictMalicious  Verdict = "malicious"
+)
+
+// IOCMatch records a single blacklist hit against an analysis event
+type IOCMatch struct {
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	Severity    int    `json:"severity"`
 }
 
 // SummaryStats contains high-level statistics
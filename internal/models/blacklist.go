@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BlacklistType represents the type of blacklist/IOC entry. It mirrors
+// WhitelistType for process/domain/ip/registry and adds the indicator
+// types whitelists have no use for.
+type BlacklistType string
+
+const (
+	BlacklistTypeProcess  BlacklistType = "process"
+	BlacklistTypeDomain   BlacklistType = "domain"
+	BlacklistTypeIP       BlacklistType = "ip"
+	BlacklistTypeRegistry BlacklistType = "registry"
+	BlacklistTypeSHA256   BlacklistType = "sha256"
+	BlacklistTypeMutex    BlacklistType = "mutex"
+)
+
+// Blacklist represents a known-bad indicator of compromise
+type Blacklist struct {
+	ID          uuid.UUID     `json:"id"`
+	Type        BlacklistType `json:"type"`
+	Value       string        `json:"value"`
+	Description string        `json:"description"`
+	Severity    int           `json:"severity"` // 0-100, higher is worse
+	IsRegex     bool          `json:"is_regex"`
+	IsCIDR      bool          `json:"is_cidr"` // type=ip only: treat Value as a CIDR range
+	Enabled     bool          `json:"enabled"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// BlacklistCreate represents the request to create a blacklist entry.
+// Enabled is a *bool (defaulting to true) so an explicit {"enabled": false}
+// can be distinguished from the field being omitted entirely.
+type BlacklistCreate struct {
+	Type        BlacklistType `json:"type" binding:"required"`
+	Value       string        `json:"value" binding:"required"`
+	Description string        `json:"description"`
+	Severity    int           `json:"severity"`
+	IsRegex     bool          `json:"is_regex"`
+	IsCIDR      bool          `json:"is_cidr"`
+	Enabled     *bool         `json:"enabled"`
+}
+
+// BlacklistUpdate represents the request to update a blacklist entry
+type BlacklistUpdate struct {
+	Value       *string `json:"value"`
+	Description *string `json:"description"`
+	Severity    *int    `json:"severity"`
+	IsRegex     *bool   `json:"is_regex"`
+	IsCIDR      *bool   `json:"is_cidr"`
+	Enabled     *bool   `json:"enabled"`
+}
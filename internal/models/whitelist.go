@@ -14,6 +14,7 @@ const (
 	WhitelistTypeDomain   WhitelistType = "domain"
 	WhitelistTypeIP       WhitelistType = "ip"
 	WhitelistTypeRegistry WhitelistType = "registry"
+	WhitelistTypeHash     WhitelistType = "hash" // SHA-256 file hash
 )
 
 // Whitelist represents a whitelist entry
@@ -23,18 +24,25 @@ type Whitelist struct {
 	Value       string        `json:"value"`
 	Description string        `json:"description"`
 	IsRegex     bool          `json:"is_regex"`
+	IsCIDR      bool          `json:"is_cidr,omitempty"` // type=ip only: treat Value as a CIDR range
 	Enabled     bool          `json:"enabled"`
-	CreatedAt   time.Time     `json:"created_at"`
-	UpdatedAt   time.Time     `json:"updated_at"`
+	// Source identifies the feed this entry was imported from (e.g. a ThreatFeed ID).
+	// Empty for hand-curated entries.
+	Source    string    `json:"source,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// WhitelistCreate represents the request to create a whitelist entry
+// WhitelistCreate represents the request to create a whitelist entry.
+// Enabled is a *bool (defaulting to true) so an explicit {"enabled": false}
+// can be distinguished from the field being omitted entirely.
 type WhitelistCreate struct {
 	Type        WhitelistType `json:"type" binding:"required"`
 	Value       string        `json:"value" binding:"required"`
 	Description string        `json:"description"`
 	IsRegex     bool          `json:"is_regex"`
-	Enabled     bool          `json:"enabled"`
+	IsCIDR      bool          `json:"is_cidr"`
+	Enabled     *bool         `json:"enabled"`
 }
 
 // WhitelistUpdate represents the request to update a whitelist entry
@@ -42,5 +50,38 @@ type WhitelistUpdate struct {
 	Value       *string `json:"value"`
 	Description *string `json:"description"`
 	IsRegex     *bool   `json:"is_regex"`
+	IsCIDR      *bool   `json:"is_cidr"`
 	Enabled     *bool   `json:"enabled"`
 }
+
+// ThreatFeedKind identifies the wire format a threat-intel feed publishes
+type ThreatFeedKind string
+
+const (
+	ThreatFeedKindSTIX ThreatFeedKind = "stix"
+	ThreatFeedKindMISP ThreatFeedKind = "misp"
+)
+
+// ThreatFeed represents a subscription to an external STIX/MISP allowlist feed.
+// Whitelist rows pulled from a feed carry its ID in Whitelist.Source so they
+// can be re-synced or purged as a group.
+type ThreatFeed struct {
+	ID             uuid.UUID      `json:"id"`
+	Name           string         `json:"name"`
+	Kind           ThreatFeedKind `json:"kind"`
+	URL            string         `json:"url"`
+	Enabled        bool           `json:"enabled"`
+	ETag           string         `json:"etag,omitempty"`
+	LastModified   string         `json:"last_modified,omitempty"`
+	LastSyncedAt   *time.Time     `json:"last_synced_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// ThreatFeedCreate represents the request to register a new threat-intel feed
+type ThreatFeedCreate struct {
+	Name    string         `json:"name" binding:"required"`
+	Kind    ThreatFeedKind `json:"kind" binding:"required"`
+	URL     string         `json:"url" binding:"required"`
+	Enabled bool           `json:"enabled"`
+}
@@ -1,25 +1,55 @@
 package proxmox
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cupax/cupax/internal/config"
 )
 
+// defaultRenewInterval is used when config.ProxmoxConfig.RenewInterval is
+// unset. PVE auth tickets are valid for ~2 hours; renewing 15 minutes early
+// leaves comfortable margin for a slow renewal call or two.
+const defaultRenewInterval = 105 * time.Minute
+
+// renewBaseBackoff and renewMaxBackoff bound the retry delay after a failed
+// ticket renewal, doubling each attempt like queue.Queue's job retry.
+const (
+	renewBaseBackoff = 10 * time.Second
+	renewMaxBackoff  = 5 * time.Minute
+)
+
+// defaultPollInterval is used when config.ProxmoxConfig.StatusPollInterval
+// is unset.
+const defaultPollInterval = 2 * time.Second
+
 // Client represents a Proxmox API client
 type Client struct {
 	config     config.ProxmoxConfig
 	httpClient *http.Client
 	baseURL    string
+
+	// mu guards authTicket and csrfToken, which are read by every doRequest
+	// call and rewritten in place by the background renewal loop.
+	mu         sync.RWMutex
 	authTicket string // For username/password authentication
 	csrfToken  string // CSRF prevention token
+
+	renewInterval time.Duration
+	stopCh        chan struct{}
+	closeOnce     sync.Once
+
+	pollInterval time.Duration
 }
 
 // SnapshotInfo represents a Proxmox snapshot
@@ -49,23 +79,47 @@ func NewClient(cfg config.ProxmoxConfig) *Client {
 		Timeout:   30 * time.Second,
 	}
 
+	renewInterval := time.Duration(cfg.RenewInterval) * time.Second
+	if renewInterval <= 0 {
+		renewInterval = defaultRenewInterval
+	}
+
+	pollInterval := time.Duration(cfg.StatusPollInterval) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
 	client := &Client{
-		config:     cfg,
-		httpClient: httpClient,
-		baseURL:    fmt.Sprintf("%s/api2/json", strings.TrimSuffix(cfg.Host, "/")),
+		config:        cfg,
+		httpClient:    httpClient,
+		baseURL:       fmt.Sprintf("%s/api2/json", strings.TrimSuffix(cfg.Host, "/")),
+		renewInterval: renewInterval,
+		stopCh:        make(chan struct{}),
+		pollInterval:  pollInterval,
 	}
 
-	// If using username/password, obtain authentication ticket
+	// If using username/password, obtain authentication ticket and start the
+	// background renewal loop that keeps it from expiring mid-run.
 	if cfg.Username != "" && cfg.Password != "" {
 		if err := client.authenticate(); err != nil {
 			// Log error but don't fail - let subsequent API calls fail with proper error
 			fmt.Printf("Warning: Failed to authenticate with Proxmox: %v\n", err)
 		}
+		go client.ticketRenewalLoop()
 	}
 
 	return client
 }
 
+// Close stops the background ticket renewal goroutine. Safe to call on a
+// client using token authentication (which never started one) and safe to
+// call more than once.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stopCh)
+	})
+}
+
 // authenticate obtains an authentication ticket using username/password
 func (c *Client) authenticate() error {
 	url := fmt.Sprintf("%s/access/ticket", c.baseURL)
@@ -104,32 +158,137 @@ func (c *Client) authenticate() error {
 		return fmt.Errorf("failed to parse auth response: %w", err)
 	}
 
+	c.mu.Lock()
 	c.authTicket = authResp.Data.Ticket
 	c.csrfToken = authResp.Data.CSRFPreventionToken
+	c.mu.Unlock()
 
 	return nil
 }
 
-// doRequest performs an HTTP request with authentication
-func (c *Client) doRequest(method, path string, body io.Reader) (*http.Response, error) {
+// renewTicket exchanges the current auth ticket for a fresh one, per the PVE
+// convention of POSTing the existing ticket back as the "password" field of
+// /access/ticket. If the existing ticket has already expired or is
+// otherwise rejected, it falls back to a full username/password
+// authenticate().
+func (c *Client) renewTicket() error {
+	c.mu.RLock()
+	ticket := c.authTicket
+	c.mu.RUnlock()
+
+	if ticket == "" {
+		return c.authenticate()
+	}
+
+	url := fmt.Sprintf("%s/access/ticket", c.baseURL)
+	data := fmt.Sprintf("username=%s&password=%s", c.config.Username, ticket)
+
+	req, err := http.NewRequest("POST", url, strings.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create renewal request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute renewal request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("proxmox: ticket renewal rejected with status %d, falling back to full re-authentication", resp.StatusCode)
+		return c.authenticate()
+	}
+
+	var authResp struct {
+		Data struct {
+			Ticket              string `json:"ticket"`
+			CSRFPreventionToken string `json:"CSRFPreventionToken"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
+		return fmt.Errorf("failed to parse renewal response: %w", err)
+	}
+
+	c.mu.Lock()
+	c.authTicket = authResp.Data.Ticket
+	c.csrfToken = authResp.Data.CSRFPreventionToken
+	c.mu.Unlock()
+
+	return nil
+}
+
+// ticketRenewalLoop renews the auth ticket on a timer until stopCh is
+// closed. A failed renewal (network error, or renewTicket's own fallback
+// re-authenticate also failing) is logged and retried with exponential
+// backoff rather than tearing the client down - subsequent API calls just
+// keep using the last good ticket until one of these attempts succeeds.
+func (c *Client) ticketRenewalLoop() {
+	timer := time.NewTimer(c.renewInterval)
+	defer timer.Stop()
+
+	attempt := 0
+	for {
+		select {
+		case <-timer.C:
+			if err := c.renewTicket(); err != nil {
+				attempt++
+				backoff := renewBaseBackoff * time.Duration(1<<uint(attempt-1))
+				if backoff > renewMaxBackoff {
+					backoff = renewMaxBackoff
+				}
+				log.Printf("proxmox: ticket renewal failed, retrying in %s: %v", backoff, err)
+				timer.Reset(backoff)
+				continue
+			}
+
+			attempt = 0
+			timer.Reset(c.renewInterval)
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+// doRequest performs an HTTP request with authentication. ctx bounds both
+// the HTTP round trip and, via the callers in this package, any polling
+// loop built on top of it - cancelling ctx lets an abandoned analysis give
+// up on a Proxmox call stuck on a slow hypervisor instead of blocking it
+// forever.
+func (c *Client) doRequest(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	return c.doRequestWithContentType(ctx, method, path, body, "application/json")
+}
+
+// doFormRequest is doRequest for endpoints (like snapshot creation) that
+// take their parameters as a form-encoded body rather than a path/query.
+func (c *Client) doFormRequest(ctx context.Context, method, path string, form url.Values) (*http.Response, error) {
+	return c.doRequestWithContentType(ctx, method, path, strings.NewReader(form.Encode()), "application/x-www-form-urlencoded")
+}
+
+func (c *Client) doRequestWithContentType(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
 	url := fmt.Sprintf("%s%s", c.baseURL, path)
 
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Type", contentType)
 	}
 
 	// Add authentication based on configured method
-	if c.authTicket != "" {
+	c.mu.RLock()
+	authTicket, csrfToken := c.authTicket, c.csrfToken
+	c.mu.RUnlock()
+
+	if authTicket != "" {
 		// Username/password authentication using ticket
-		req.Header.Set("Cookie", fmt.Sprintf("PVEAuthCookie=%s", c.authTicket))
+		req.Header.Set("Cookie", fmt.Sprintf("PVEAuthCookie=%s", authTicket))
 		if method != "GET" {
 			// Add CSRF token for state-changing operations
-			req.Header.Set("CSRFPreventionToken", c.csrfToken)
+			req.Header.Set("CSRFPreventionToken", csrfToken)
 		}
 	} else if c.config.TokenID != "" && c.config.TokenSecret != "" {
 		// API token authentication
@@ -146,11 +305,23 @@ func (c *Client) doRequest(method, path string, body io.Reader) (*http.Response,
 	return resp, nil
 }
 
+// guestBasePath returns the node-scoped API path prefix for the configured
+// guest type, e.g. "/nodes/pve/qemu/100" for a VM or "/nodes/pve/lxc/100"
+// for a container. Everything below it (snapshot and status/* endpoints) is
+// identical between the two guest types.
+func (c *Client) guestBasePath() string {
+	kind := c.config.Guest
+	if kind == "" {
+		kind = config.GuestQEMU
+	}
+	return fmt.Sprintf("/nodes/%s/%s/%d", c.config.Node, kind, c.config.VMID)
+}
+
 // GetSnapshots returns all snapshots for the configured VM
-func (c *Client) GetSnapshots() ([]SnapshotInfo, error) {
-	path := fmt.Sprintf("/nodes/%s/qemu/%d/snapshot", c.config.Node, c.config.VMID)
+func (c *Client) GetSnapshots(ctx context.Context) ([]SnapshotInfo, error) {
+	path := c.guestBasePath() + "/snapshot"
 
-	resp, err := c.doRequest("GET", path, nil)
+	resp, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -181,8 +352,8 @@ func (c *Client) GetSnapshots() ([]SnapshotInfo, error) {
 }
 
 // GetLatestSnapshot returns the most recent snapshot (excluding 'current' state)
-func (c *Client) GetLatestSnapshot() (*SnapshotInfo, error) {
-	snapshots, err := c.GetSnapshots()
+func (c *Client) GetLatestSnapshot(ctx context.Context) (*SnapshotInfo, error) {
+	snapshots, err := c.GetSnapshots(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -207,105 +378,330 @@ func (c *Client) GetLatestSnapshot() (*SnapshotInfo, error) {
 	return &realSnapshots[0], nil
 }
 
-// RollbackToSnapshot restores VM to a specific snapshot
-func (c *Client) RollbackToSnapshot(snapshotName string) error {
-	path := "/nodes/ludus/qemu/111/snapshot/cupax/rollback"
+// CreateSnapshot takes a new snapshot of the configured VM, e.g. a
+// pre-detonation checkpoint taken per job instead of relying on one
+// human-curated baseline. withMemory also captures RAM state (a larger,
+// slower snapshot) so a rollback can resume a running VM instead of just
+// restoring disk state.
+func (c *Client) CreateSnapshot(ctx context.Context, name, description string, withMemory bool) (*TaskRef, error) {
+	path := c.guestBasePath() + "/snapshot"
+
+	form := url.Values{}
+	form.Set("snapname", name)
+	if description != "" {
+		form.Set("description", description)
+	}
+	if withMemory {
+		form.Set("vmstate", "1")
+	}
+
+	resp, err := c.doFormRequest(ctx, "POST", path, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("create snapshot failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return parseTaskRef(c.config.Node, body)
+}
+
+// DeleteSnapshot removes a snapshot by name.
+func (c *Client) DeleteSnapshot(ctx context.Context, name string) (*TaskRef, error) {
+	path := c.guestBasePath() + "/snapshot/" + name
+
+	resp, err := c.doRequest(ctx, "DELETE", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("delete snapshot failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return parseTaskRef(c.config.Node, body)
+}
 
-	resp, err := c.doRequest("POST", path, nil)
+// UpdateSnapshotDescription rewrites a snapshot's description in place.
+func (c *Client) UpdateSnapshotDescription(ctx context.Context, name, description string) error {
+	path := c.guestBasePath() + "/snapshot/" + name + "/config"
+
+	form := url.Values{}
+	form.Set("description", description)
+
+	resp, err := c.doFormRequest(ctx, "PUT", path, form)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	// Proxmox returns 200 OK for successful operations
-	// The response body contains a task UPID (can be empty or plain text)
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("rollback failed with status %d: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("update snapshot description failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	// Success - don't try to parse response body
 	return nil
 }
 
+// SnapshotRetentionPolicy bounds how many pre-detonation checkpoints a VM
+// is allowed to accumulate. Snapshots named in KeepNames - a curated
+// "golden" baseline, say - are never pruned regardless of MaxCount/MaxAge.
+type SnapshotRetentionPolicy struct {
+	MaxCount  int           // 0 disables count-based pruning
+	MaxAge    time.Duration // 0 disables age-based pruning
+	KeepNames []string
+}
+
+// ApplyRetention lists the VM's snapshots and deletes whatever falls
+// outside policy: everything past the MaxCount newest, and everything
+// older than MaxAge, except snapshots named in policy.KeepNames or the
+// 'current' pseudo-snapshot. It keeps deleting and returns whatever
+// snapshots it successfully removed even if a later deletion fails.
+func (c *Client) ApplyRetention(ctx context.Context, policy SnapshotRetentionPolicy) ([]string, error) {
+	snapshots, err := c.GetSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool, len(policy.KeepNames)+1)
+	keep["current"] = true
+	for _, name := range policy.KeepNames {
+		keep[name] = true
+	}
+
+	var candidates []SnapshotInfo
+	for _, snap := range snapshots {
+		if !keep[snap.Name] {
+			candidates = append(candidates, snap)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].SnapTime > candidates[j].SnapTime
+	})
+
+	var cutoff time.Time
+	if policy.MaxAge > 0 {
+		cutoff = time.Now().Add(-policy.MaxAge)
+	}
+
+	var deleted []string
+	var firstErr error
+	for i, snap := range candidates {
+		tooOld := policy.MaxAge > 0 && time.Unix(snap.SnapTime, 0).Before(cutoff)
+		tooMany := policy.MaxCount > 0 && i >= policy.MaxCount
+		if !tooOld && !tooMany {
+			continue
+		}
+
+		if _, err := c.DeleteSnapshot(ctx, snap.Name); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to delete snapshot %s: %w", snap.Name, err)
+			}
+			continue
+		}
+		deleted = append(deleted, snap.Name)
+	}
+
+	return deleted, firstErr
+}
+
+// TaskRef identifies a background task Proxmox started in response to an
+// action call (rollback, shutdown, stop), as returned in the response body.
+// Pass it to WaitForTask to block until the task actually finishes, rather
+// than just until Proxmox accepted the request.
+type TaskRef struct {
+	Node string
+	UPID string
+}
+
+// parseTaskRef extracts the task UPID from an action endpoint's response
+// body. Proxmox returns it as a bare string in the "data" field; some
+// actions (or a no-op request) return an empty body instead, which yields a
+// nil TaskRef - WaitForTask treats that as already complete.
+func parseTaskRef(node string, body []byte) (*TaskRef, error) {
+	body = []byte(strings.TrimSpace(string(body)))
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	var result ProxmoxResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode task response: %w", err)
+	}
+
+	upid, _ := result.Data.(string)
+	upid = strings.TrimSpace(upid)
+	if upid == "" {
+		return nil, nil
+	}
+
+	return &TaskRef{Node: node, UPID: upid}, nil
+}
+
+// RollbackToSnapshot restores VM to a specific snapshot
+func (c *Client) RollbackToSnapshot(ctx context.Context, snapshotName string) (*TaskRef, error) {
+	path := c.guestBasePath() + "/snapshot/" + snapshotName + "/rollback"
+
+	resp, err := c.doRequest(ctx, "POST", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rollback failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return parseTaskRef(c.config.Node, body)
+}
+
 // RollbackToLatest restores VM to the most recent snapshot
-func (c *Client) RollbackToLatest() error {
-	latest, err := c.GetLatestSnapshot()
+func (c *Client) RollbackToLatest(ctx context.Context) (*TaskRef, error) {
+	latest, err := c.GetLatestSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.RollbackToSnapshot(ctx, latest.Name)
+}
+
+// RollbackToLatestAndWait restores the VM to its most recent snapshot and
+// blocks until Proxmox reports the rollback task finished.
+func (c *Client) RollbackToLatestAndWait(ctx context.Context, timeout time.Duration) error {
+	ref, err := c.RollbackToLatest(ctx)
 	if err != nil {
 		return err
 	}
 
-	return c.RollbackToSnapshot(latest.Name)
+	return c.WaitForTask(ctx, ref, timeout)
 }
 
 // ShutdownVM initiates a graceful shutdown of the VM
-func (c *Client) ShutdownVM() error {
-	path := fmt.Sprintf("/nodes/%s/qemu/%d/status/shutdown", c.config.Node, c.config.VMID)
+func (c *Client) ShutdownVM(ctx context.Context) (*TaskRef, error) {
+	path := c.guestBasePath() + "/status/shutdown"
 
-	resp, err := c.doRequest("POST", path, nil)
+	resp, err := c.doRequest(ctx, "POST", path, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// Proxmox returns 200 OK for successful operations
-	// The response body contains a task UPID (can be empty or plain text)
+	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("shutdown failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("shutdown failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	// Success - don't try to parse response body
-	return nil
+	return parseTaskRef(c.config.Node, body)
+}
+
+// ShutdownAndWait gracefully shuts down the VM and blocks until Proxmox
+// reports the shutdown task finished.
+func (c *Client) ShutdownAndWait(ctx context.Context, timeout time.Duration) error {
+	ref, err := c.ShutdownVM(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.WaitForTask(ctx, ref, timeout)
 }
 
 // StopVM forces the VM to stop (like pulling the power)
-func (c *Client) StopVM() error {
-	path := fmt.Sprintf("/nodes/%s/qemu/%d/status/stop", c.config.Node, c.config.VMID)
+func (c *Client) StopVM(ctx context.Context) (*TaskRef, error) {
+	path := c.guestBasePath() + "/status/stop"
 
-	resp, err := c.doRequest("POST", path, nil)
+	resp, err := c.doRequest(ctx, "POST", path, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// Proxmox returns 200 OK for successful operations
-	// The response body contains a task UPID (can be empty or plain text)
+	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("stop failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("stop failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	// Success - don't try to parse response body
-	return nil
+	return parseTaskRef(c.config.Node, body)
 }
 
 // StartVM starts the VM
-func (c *Client) StartVM() error {
-	path := fmt.Sprintf("/nodes/%s/qemu/%d/status/start", c.config.Node, c.config.VMID)
+func (c *Client) StartVM(ctx context.Context) (*TaskRef, error) {
+	path := c.guestBasePath() + "/status/start"
 
-	resp, err := c.doRequest("POST", path, nil)
+	resp, err := c.doRequest(ctx, "POST", path, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// Proxmox returns 200 OK for successful operations
-	// The response body contains a task UPID (can be empty or plain text)
+	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("start failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("start failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	// Success - don't try to parse response body
-	return nil
+	return parseTaskRef(c.config.Node, body)
+}
+
+// WaitForTask polls a task's status until it finishes or the timeout (or
+// ctx) elapses, returning an error if it finishes with an exitstatus other
+// than "OK". A nil ref - an action that never started a task - is treated
+// as already done.
+func (c *Client) WaitForTask(ctx context.Context, ref *TaskRef, timeout time.Duration) error {
+	if ref == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	path := fmt.Sprintf("/nodes/%s/tasks/%s/status", ref.Node, ref.UPID)
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		resp, err := c.doRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return err
+		}
+
+		var result ProxmoxResponse
+		decodeErr := json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if decodeErr != nil {
+			return fmt.Errorf("failed to decode task status: %w", decodeErr)
+		}
+
+		status, ok := result.Data.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unexpected task status format")
+		}
+
+		if status["status"] == "stopped" {
+			if exitStatus, _ := status["exitstatus"].(string); exitStatus != "OK" {
+				return fmt.Errorf("task %s finished with non-OK exit status: %s", ref.UPID, exitStatus)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
 }
 
 // GetVMStatus returns the current VM status
-func (c *Client) GetVMStatus() (string, error) {
-	path := fmt.Sprintf("/nodes/%s/qemu/%d/status/current", c.config.Node, c.config.VMID)
+func (c *Client) GetVMStatus(ctx context.Context) (string, error) {
+	path := c.guestBasePath() + "/status/current"
 
-	resp, err := c.doRequest("GET", path, nil)
+	resp, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return "", err
 	}
@@ -335,12 +731,17 @@ func (c *Client) GetVMStatus() (string, error) {
 	return status, nil
 }
 
-// WaitForStatus waits for VM to reach a specific status
-func (c *Client) WaitForStatus(targetStatus string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
+// WaitForStatus waits for VM to reach a specific status, giving up early if
+// ctx is cancelled instead of riding out the full timeout.
+func (c *Client) WaitForStatus(ctx context.Context, targetStatus string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
 
-	for time.Now().Before(deadline) {
-		status, err := c.GetVMStatus()
+	for {
+		status, err := c.GetVMStatus(ctx)
 		if err != nil {
 			return err
 		}
@@ -349,8 +750,10 @@ func (c *Client) WaitForStatus(targetStatus string, timeout time.Duration) error
 			return nil
 		}
 
-		time.Sleep(2 * time.Second)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
 	}
-
-	return fmt.Errorf("timeout waiting for VM to reach status: %s", targetStatus)
 }
@@ -0,0 +1,70 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cupax/cupax/internal/config"
+)
+
+// newTestServer serves /access/ticket with a ticket that rotates on every
+// call and a status endpoint that records the Cookie header it was sent, so
+// tests can tell which ticket doRequest actually used.
+func newTestServer(ticketCounter *int64, lastCookie *string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api2/json/access/ticket", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(ticketCounter, 1)
+		fmt.Fprintf(w, `{"data":{"ticket":"ticket-%d","CSRFPreventionToken":"csrf-%d"}}`, n, n)
+	})
+	mux.HandleFunc("/api2/json/nodes/pve/qemu/100/status/current", func(w http.ResponseWriter, r *http.Request) {
+		*lastCookie = r.Header.Get("Cookie")
+		fmt.Fprint(w, `{"data":{"status":"running"}}`)
+	})
+	return httptest.NewServer(mux)
+}
+
+// TestDoRequestUsesFreshTicketAfterRenewal rotates the ticket out from under
+// a live Client and checks that doRequest picks up the new one rather than
+// replaying the cookie it authenticated with initially.
+func TestDoRequestUsesFreshTicketAfterRenewal(t *testing.T) {
+	var ticketCounter int64
+	var lastCookie string
+	server := newTestServer(&ticketCounter, &lastCookie)
+	defer server.Close()
+
+	client := NewClient(config.ProxmoxConfig{
+		Host:     server.URL,
+		Node:     "pve",
+		VMID:     100,
+		Username: "root@pam",
+		Password: "hunter2",
+	})
+	defer client.Close()
+
+	if _, err := client.GetVMStatus(context.Background()); err != nil {
+		t.Fatalf("GetVMStatus after initial auth: %v", err)
+	}
+	firstCookie := lastCookie
+	if !strings.Contains(firstCookie, "PVEAuthCookie=") {
+		t.Fatalf("expected a PVEAuthCookie on the first request, got %q", firstCookie)
+	}
+
+	if err := client.renewTicket(); err != nil {
+		t.Fatalf("renewTicket: %v", err)
+	}
+
+	if _, err := client.GetVMStatus(context.Background()); err != nil {
+		t.Fatalf("GetVMStatus after renewal: %v", err)
+	}
+	if lastCookie == firstCookie {
+		t.Fatalf("doRequest sent a stale cookie after renewal: both requests used %q", lastCookie)
+	}
+	if !strings.Contains(lastCookie, "PVEAuthCookie=") {
+		t.Fatalf("expected a PVEAuthCookie after renewal, got %q", lastCookie)
+	}
+}
@@ -0,0 +1,65 @@
+package proxmox
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RetentionWorker periodically applies a SnapshotRetentionPolicy to the
+// configured guest, pruning pre-detonation checkpoints so parallel VM usage
+// doesn't accumulate snapshots without bound.
+type RetentionWorker struct {
+	client   *Client
+	policy   SnapshotRetentionPolicy
+	interval time.Duration
+}
+
+// NewRetentionWorker creates a new snapshot retention worker. interval
+// controls how often Run sweeps for snapshots to prune; a single pass can
+// always be triggered with RunOnce. A non-positive interval falls back to
+// an hour.
+func NewRetentionWorker(client *Client, policy SnapshotRetentionPolicy, interval time.Duration) *RetentionWorker {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &RetentionWorker{
+		client:   client,
+		policy:   policy,
+		interval: interval,
+	}
+}
+
+// Run blocks, sweeping on each tick of the worker's interval, until stopCh
+// is closed.
+func (w *RetentionWorker) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.RunOnce()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.RunOnce()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// RunOnce applies the retention policy a single time, logging whatever
+// snapshots were pruned.
+func (w *RetentionWorker) RunOnce() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deleted, err := w.client.ApplyRetention(ctx, w.policy)
+	if err != nil {
+		log.Printf("proxmox: snapshot retention sweep failed: %v", err)
+	}
+	if len(deleted) > 0 {
+		log.Printf("proxmox: snapshot retention pruned %d snapshot(s): %v", len(deleted), deleted)
+	}
+}
@@ -0,0 +1,52 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cupax/cupax/internal/config"
+)
+
+// TestWaitForStatusReturnsContextErrWhenCancelled cancels the caller's
+// context partway through a poll loop that would otherwise keep waiting for
+// up to a minute, and checks the call unwinds promptly instead of riding out
+// the timeout.
+func TestWaitForStatusReturnsContextErrWhenCancelled(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api2/json/nodes/pve/qemu/100/status/current", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"status":"running"}}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(config.ProxmoxConfig{
+		Host:               server.URL,
+		Node:               "pve",
+		VMID:               100,
+		TokenID:            "root@pam!cupax",
+		TokenSecret:        "secret",
+		StatusPollInterval: 10, // seconds - long enough that the ticker never fires before cancel
+	})
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	err := client.WaitForStatus(ctx, "stopped", time.Minute)
+	elapsed := time.Since(start)
+
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed > 50*time.Millisecond {
+		t.Fatalf("WaitForStatus took %s to return after cancellation, want ~50ms or less", elapsed)
+	}
+}
@@ -0,0 +1,222 @@
+// Package queue implements an in-process, channel-backed worker pool for
+// background analysis tasks. It exists so HandleUploadFile can return as
+// soon as a sample is accepted instead of blocking the request for the full
+// analyzer timeout; the durable record of an analysis is always the
+// analyses table, so the queue only needs to drive delivery and retry of
+// the in-flight attempt, not persist task state itself.
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cupax/cupax/internal/metrics"
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-hclog"
+)
+
+// AnalyzeTask is the payload enqueued for each sample analysis.
+type AnalyzeTask struct {
+	AnalysisID  uuid.UUID
+	SampleURI   string
+	IsZip       bool
+	ZipPassword string
+
+	// EnqueuedAt is stamped by Enqueue and read back by the handler to
+	// measure time spent waiting for a free worker.
+	EnqueuedAt time.Time
+}
+
+// Handler processes a single AnalyzeTask. Returning an error causes the
+// queue to retry the task, up to DefaultMaxRetries times with exponential backoff,
+// before moving it to the dead-letter queue. ctx is cancelled if the task
+// is cancelled via Cancel while queued or running.
+type Handler func(ctx context.Context, task AnalyzeTask) error
+
+// Stats is a snapshot of queue depth and worker activity, surfaced on
+// /health.
+type Stats struct {
+	Workers      int `json:"workers"`
+	Depth        int `json:"depth"`
+	Running      int `json:"running"`
+	DeadLettered int `json:"dead_lettered"`
+}
+
+const (
+	// DefaultMaxRetries is how many times a failing task is retried before
+	// it is moved to the dead-letter queue.
+	DefaultMaxRetries = 3
+	// DefaultBaseBackoff is doubled on each retry attempt.
+	DefaultBaseBackoff = 2 * time.Second
+)
+
+// job wraps a task with its retry bookkeeping and the context used to run
+// it, so Cancel can stop a queued-but-not-yet-started or in-flight task.
+type job struct {
+	task    AnalyzeTask
+	attempt int
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// Queue is an in-process worker pool of AnalyzeTasks.
+type Queue struct {
+	handler     Handler
+	workers     int
+	maxRetries  int
+	baseBackoff time.Duration
+	logger      hclog.Logger
+	metrics     *metrics.Metrics
+
+	jobs chan job
+
+	mu         sync.Mutex
+	cancels    map[uuid.UUID]context.CancelFunc
+	depth      int
+	running    int
+	deadLetter []AnalyzeTask
+}
+
+// New creates a queue with the given handler and worker count, and starts
+// the worker goroutines immediately. A non-positive workers defaults to 1.
+// Every line logged about a task is stamped with its analysis_id. m may be
+// nil, in which case queue depth isn't exported as a metric.
+func New(workers int, handler Handler, logger hclog.Logger, m *metrics.Metrics) *Queue {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	q := &Queue{
+		handler:     handler,
+		workers:     workers,
+		maxRetries:  DefaultMaxRetries,
+		baseBackoff: DefaultBaseBackoff,
+		logger:      logger,
+		metrics:     m,
+		jobs:        make(chan job, 1024),
+		cancels:     make(map[uuid.UUID]context.CancelFunc),
+	}
+
+	for i := 0; i < workers; i++ {
+		go q.worker(i)
+	}
+
+	return q
+}
+
+// Enqueue schedules a task to run on the next free worker.
+func (q *Queue) Enqueue(task AnalyzeTask) {
+	task.EnqueuedAt = time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	q.mu.Lock()
+	q.cancels[task.AnalysisID] = cancel
+	q.depth++
+	q.reportDepthLocked()
+	q.mu.Unlock()
+
+	q.jobs <- job{task: task, ctx: ctx, cancel: cancel}
+}
+
+// Cancel stops analysisID's task: if it hasn't started yet it is dropped
+// without running, and if it's already running its context is cancelled so
+// a context-aware handler can abort early. Returns false if no queued or
+// running task exists for that analysis.
+func (q *Queue) Cancel(analysisID uuid.UUID) bool {
+	q.mu.Lock()
+	cancel, ok := q.cancels[analysisID]
+	q.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	cancel()
+	return true
+}
+
+// Stats returns a snapshot of queue depth and worker activity.
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return Stats{
+		Workers:      q.workers,
+		Depth:        q.depth,
+		Running:      q.running,
+		DeadLettered: len(q.deadLetter),
+	}
+}
+
+func (q *Queue) worker(id int) {
+	for j := range q.jobs {
+		if j.ctx.Err() != nil {
+			// Cancelled before a worker ever picked it up.
+			q.finish(j.task.AnalysisID, 1)
+			continue
+		}
+
+		q.mu.Lock()
+		q.depth--
+		q.running++
+		q.reportDepthLocked()
+		q.mu.Unlock()
+
+		err := q.handler(j.ctx, j.task)
+
+		q.mu.Lock()
+		q.running--
+		q.mu.Unlock()
+
+		if err != nil && j.ctx.Err() == nil {
+			if j.attempt < q.maxRetries {
+				q.retry(j)
+				continue
+			}
+
+			q.logger.Error("task exhausted retries, moving to dead letter", "analysis_id", j.task.AnalysisID, "error", err)
+			q.mu.Lock()
+			q.deadLetter = append(q.deadLetter, j.task)
+			q.mu.Unlock()
+		}
+
+		q.finish(j.task.AnalysisID, 0)
+	}
+}
+
+// retry re-enqueues j after an exponential backoff delay, bumping its
+// depth count back up so Stats reflects the pending retry.
+func (q *Queue) retry(j job) {
+	j.attempt++
+	backoff := q.baseBackoff * time.Duration(1<<uint(j.attempt-1))
+	q.logger.Warn("task failed, retrying", "analysis_id", j.task.AnalysisID, "backoff", backoff, "attempt", j.attempt, "max_retries", q.maxRetries)
+
+	q.mu.Lock()
+	q.depth++
+	q.reportDepthLocked()
+	q.mu.Unlock()
+
+	time.AfterFunc(backoff, func() {
+		q.jobs <- j
+	})
+}
+
+// finish clears a task's cancel func once it will never run again. delta is
+// 1 when the job never ran (cancelled while queued) so Stats.Depth stays
+// accurate.
+func (q *Queue) finish(analysisID uuid.UUID, delta int) {
+	q.mu.Lock()
+	delete(q.cancels, analysisID)
+	q.depth -= delta
+	q.reportDepthLocked()
+	q.mu.Unlock()
+}
+
+// reportDepthLocked publishes the current depth to QueueDepth. Callers must
+// hold q.mu.
+func (q *Queue) reportDepthLocked() {
+	if q.metrics != nil {
+		q.metrics.SetQueueDepth(q.depth)
+	}
+}
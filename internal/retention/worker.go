@@ -0,0 +1,106 @@
+// Package retention implements the background lifecycle sweep that keeps
+// the analyses table from growing unbounded: completed analyses past their
+// archive age are compressed to disk, and archives past their purge age are
+// deleted outright.
+package retention
+
+import (
+	"log"
+	"time"
+
+	"github.com/cupax/cupax/internal/database"
+)
+
+// Worker periodically archives completed analyses older than ArchiveAfter
+// and purges archives older than PurgeAfter.
+type Worker struct {
+	repo         *database.Repository
+	archiveDir   string
+	archiveAfter time.Duration
+	purgeAfter   time.Duration
+	interval     time.Duration
+}
+
+// NewWorker creates a new retention worker. interval controls how often Run
+// sweeps for analyses to archive/purge; a single pass can always be
+// triggered with RunOnce. A non-positive archiveAfter or purgeAfter disables
+// that half of the sweep.
+func NewWorker(repo *database.Repository, archiveDir string, archiveAfter, purgeAfter, interval time.Duration) *Worker {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &Worker{
+		repo:         repo,
+		archiveDir:   archiveDir,
+		archiveAfter: archiveAfter,
+		purgeAfter:   purgeAfter,
+		interval:     interval,
+	}
+}
+
+// Run blocks, sweeping on each tick of the worker's interval, until stopCh
+// is closed
+func (w *Worker) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.RunOnce()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.RunOnce()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// RunOnce archives everything old enough to archive and purges everything
+// old enough to purge, a single time. Failures are logged per-analysis so
+// one bad record doesn't block the rest of the sweep.
+func (w *Worker) RunOnce() {
+	w.archiveDue()
+	w.purgeDue()
+}
+
+func (w *Worker) archiveDue() {
+	if w.archiveAfter <= 0 {
+		return
+	}
+
+	ids, err := w.repo.ListArchivableAnalysisIDs(time.Now().Add(-w.archiveAfter))
+	if err != nil {
+		log.Printf("retention: failed to list archivable analyses: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		if err := w.repo.Archive(id, w.archiveDir); err != nil {
+			log.Printf("retention: failed to archive analysis %s: %v", id, err)
+			continue
+		}
+		log.Printf("retention: archived analysis %s", id)
+	}
+}
+
+func (w *Worker) purgeDue() {
+	if w.purgeAfter <= 0 {
+		return
+	}
+
+	ids, err := w.repo.ListPurgeableAnalysisIDs(time.Now().Add(-w.purgeAfter))
+	if err != nil {
+		log.Printf("retention: failed to list purgeable analyses: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		if err := w.repo.PurgeAnalysis(id); err != nil {
+			log.Printf("retention: failed to purge analysis %s: %v", id, err)
+			continue
+		}
+		log.Printf("retention: purged analysis %s", id)
+	}
+}
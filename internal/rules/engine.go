@@ -0,0 +1,202 @@
+package rules
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/cupax/cupax/internal/models"
+)
+
+// Engine is a RulePack with every regex precompiled, ready to Score
+// reports against repeatedly.
+type Engine struct {
+	rules []compiledRule
+}
+
+// compiledRule pairs a Rule with its precompiled regexes so Score never
+// recompiles a pattern per report.
+type compiledRule struct {
+	rule                Rule
+	cmdlineRegex        *regexp.Regexp
+	registryKeyPatterns []*regexp.Regexp
+	networkDestinations []*regexp.Regexp
+	fileWritePaths      []*regexp.Regexp
+}
+
+// NewEngine compiles pack's regexes once, so a bad pattern is caught at
+// startup rather than on the first matching analysis.
+func NewEngine(pack RulePack) (*Engine, error) {
+	compiled := make([]compiledRule, 0, len(pack.Rules))
+
+	for _, rule := range pack.Rules {
+		cr := compiledRule{rule: rule}
+
+		if rule.CmdlineRegex != "" {
+			re, err := regexp.Compile(rule.CmdlineRegex)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: invalid cmdline_regex: %w", rule.ID, err)
+			}
+			cr.cmdlineRegex = re
+		}
+
+		var err error
+		if cr.registryKeyPatterns, err = compilePatterns(rule.ID, "registry_key_patterns", rule.RegistryKeyPatterns); err != nil {
+			return nil, err
+		}
+		if cr.networkDestinations, err = compilePatterns(rule.ID, "network_destinations", rule.NetworkDestinations); err != nil {
+			return nil, err
+		}
+		if cr.fileWritePaths, err = compilePatterns(rule.ID, "file_write_paths", rule.FileWritePaths); err != nil {
+			return nil, err
+		}
+
+		compiled = append(compiled, cr)
+	}
+
+	return &Engine{rules: compiled}, nil
+}
+
+func compilePatterns(ruleID, field string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: invalid %s %q: %w", ruleID, field, p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Score runs every rule against report, folding the result into three
+// fields: Score (0-100, combined with the IOC score already on the
+// report), Verdict, and the deduplicated list of matched ATT&CK
+// technique IDs. Call after filter.IOCMatcher.MatchReport, so Score can
+// see (and fold in) IOCScore.
+func (e *Engine) Score(report *models.AnalysisReport) *models.AnalysisReport {
+	if report == nil {
+		return report
+	}
+
+	score := report.IOCScore
+	var techniques []string
+	seen := make(map[string]struct{})
+
+	for _, cr := range e.rules {
+		if !cr.matches(report) {
+			continue
+		}
+		score += cr.rule.Severity
+		if _, ok := seen[cr.rule.TechniqueID]; !ok {
+			seen[cr.rule.TechniqueID] = struct{}{}
+			techniques = append(techniques, cr.rule.TechniqueID)
+		}
+	}
+
+	if score > 100 {
+		score = 100
+	}
+
+	report.Score = score
+	report.Techniques = techniques
+	report.Verdict = verdictForScore(score)
+
+	return report
+}
+
+// matches reports whether any of cr's populated criteria hits an event in
+// report.
+func (cr *compiledRule) matches(report *models.AnalysisReport) bool {
+	for _, p := range report.ProcessActivity {
+		if matchesProcessName(cr.rule.ProcessNames, p.ProcessName) {
+			return true
+		}
+		if cr.cmdlineRegex != nil && cr.cmdlineRegex.MatchString(p.CommandLine) {
+			return true
+		}
+	}
+
+	for _, f := range report.FileSystem {
+		if f.Operation != "CreateFile" {
+			continue
+		}
+		if matchesAny(cr.fileWritePaths, f.Path) {
+			return true
+		}
+	}
+
+	for _, r := range report.Registry {
+		if matchesAny(cr.registryKeyPatterns, r.Path) {
+			return true
+		}
+	}
+
+	if len(cr.networkDestinations) > 0 && cr.matchesNetworkCadence(report.Network) {
+		return true
+	}
+
+	return false
+}
+
+// matchesNetworkCadence groups matching events by remote host and reports
+// whether any single host was hit at least MinNetworkHits times (1 if
+// unset) - the rule's proxy for beacon-like repeated contact.
+func (cr *compiledRule) matchesNetworkCadence(events []models.NetworkEvent) bool {
+	minHits := cr.rule.MinNetworkHits
+	if minHits <= 0 {
+		minHits = 1
+	}
+
+	hitsByHost := make(map[string]int)
+	for _, n := range events {
+		if matchesAny(cr.networkDestinations, n.RemoteAddr) {
+			host := n.RemoteAddr
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			hitsByHost[host]++
+		}
+	}
+
+	for _, hits := range hitsByHost {
+		if hits >= minHits {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesProcessName(names []string, processName string) bool {
+	for _, n := range names {
+		if strings.Contains(strings.ToLower(processName), strings.ToLower(n)) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []*regexp.Regexp, value string) bool {
+	for _, re := range patterns {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// verdictForScore buckets an aggregate score into a Verdict, mirroring
+// filter.verdictForScore's thresholds so the two scoring passes agree on
+// what counts as suspicious vs. malicious.
+func verdictForScore(score int) models.Verdict {
+	switch {
+	case score <= 0:
+		return models.VerdictClean
+	case score < 50:
+		return models.VerdictSuspicious
+	default:
+		return models.VerdictMalicious
+	}
+}
@@ -0,0 +1,111 @@
+// Package rules implements a YARA-like post-analysis scoring pass: a YAML
+// rule pack matches process names, command-line patterns, registry keys,
+// network destinations, and file write paths against an already
+// whitelist/IOC-filtered AnalysisReport, and maps each hit to a MITRE
+// ATT&CK technique ID and a severity weight. See Engine.Score.
+package rules
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RulePackVersion is the current rule pack format version.
+const RulePackVersion = 1
+
+// Rule matches one behavior against an AnalysisReport. A rule matches if
+// ANY populated criterion matches an event in the report - criteria are
+// independent checks, not ANDed together, so a rule keyed only on
+// RegistryKeyPatterns doesn't also require a process-name hit.
+type Rule struct {
+	ID          string `yaml:"id"`
+	TechniqueID string `yaml:"technique_id"` // MITRE ATT&CK technique, e.g. "T1547.001"
+	Description string `yaml:"description"`
+	Severity    int    `yaml:"severity"` // 0-100 weight added to Score on a match
+
+	ProcessNames        []string `yaml:"process_names,omitempty"`         // case-insensitive substring match against process names
+	CmdlineRegex        string   `yaml:"cmdline_regex,omitempty"`         // matched against ProcessActivity.CommandLine
+	RegistryKeyPatterns []string `yaml:"registry_key_patterns,omitempty"` // regexes, matched against RegistryEvent.Path
+	NetworkDestinations []string `yaml:"network_destinations,omitempty"`  // regexes, matched against NetworkEvent.RemoteAddr
+	FileWritePaths      []string `yaml:"file_write_paths,omitempty"`      // regexes, matched against FileSystemEvent.Path on a write
+
+	// MinNetworkHits requires at least this many events to the same host
+	// matching NetworkDestinations before the rule fires - a crude proxy
+	// for C2 beacon cadence (repeated contact with one handler) that
+	// doesn't need real connection timing. 0 defaults to 1 (any match).
+	MinNetworkHits int `yaml:"min_network_hits,omitempty"`
+}
+
+// RulePack is the YAML-serializable rule DSL document.
+type RulePack struct {
+	Version int    `yaml:"version"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+// DefaultRulePack returns the built-in rule pack: persistence via the Run
+// key, LSASS credential access, base64-encoded PowerShell, and C2 beacon
+// cadence. LoadRulePack falls back to this when no pack file is
+// configured, the same "ship sane defaults for a fresh install" convention
+// database.Repository.SeedDefaultWhitelists uses for whitelists.
+func DefaultRulePack() RulePack {
+	return RulePack{
+		Version: RulePackVersion,
+		Rules: []Rule{
+			{
+				ID:          "persistence-run-key",
+				TechniqueID: "T1547.001",
+				Description: "Persistence via the Run/RunOnce registry keys",
+				Severity:    40,
+				RegistryKeyPatterns: []string{
+					`(?i)\\Software\\Microsoft\\Windows\\CurrentVersion\\Run(Once)?\\`,
+				},
+			},
+			{
+				ID:          "lsass-access",
+				TechniqueID: "T1003.001",
+				Description: "Credential dumping via LSASS memory access",
+				Severity:    80,
+				CmdlineRegex: `(?i)(procdump|mimikatz|sqldumper|rundll32|comsvcs)` +
+					`.{0,80}lsass`,
+			},
+			{
+				ID:           "base64-powershell",
+				TechniqueID:  "T1059.001",
+				Description:  "Obfuscated PowerShell launched with a base64-encoded command",
+				Severity:     50,
+				CmdlineRegex: `(?i)powershell(\.exe)?.{0,40}(-enc|-e |-encodedcommand)\s+[A-Za-z0-9+/=]{20,}`,
+			},
+			{
+				ID:                  "c2-beacon-cadence",
+				TechniqueID:         "T1071",
+				Description:         "Repeated outbound connections to the same host, consistent with C2 beaconing",
+				Severity:            60,
+				NetworkDestinations: []string{`.*`},
+				MinNetworkHits:      5,
+			},
+		},
+	}
+}
+
+// LoadRulePack reads a YAML rule pack from path. An empty path returns
+// DefaultRulePack() rather than erroring, so a server with no rule pack
+// configured still scores analyses against the built-in defaults.
+func LoadRulePack(path string) (RulePack, error) {
+	if path == "" {
+		return DefaultRulePack(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RulePack{}, fmt.Errorf("failed to read rule pack %s: %w", path, err)
+	}
+
+	var pack RulePack
+	if err := yaml.Unmarshal(data, &pack); err != nil {
+		return RulePack{}, fmt.Errorf("failed to parse rule pack %s: %w", path, err)
+	}
+
+	return pack, nil
+}
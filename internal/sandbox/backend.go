@@ -0,0 +1,31 @@
+// Package sandbox abstracts the VM/hypervisor lifecycle control around an
+// analysis behind a single Backend interface, so Analyzer never hard-codes
+// a specific hypervisor. See the proxmox, libvirt, virtualbox, and external
+// subpackages for the concrete implementations, and config.SandboxConfig
+// for how a deployment picks one.
+package sandbox
+
+import "context"
+
+// Backend rolls a sandbox guest back to a clean state before an analysis
+// and shuts it back down afterward. Implementations wrap whatever
+// hypervisor or tooling actually controls the guest (Proxmox, libvirt,
+// VirtualBox's VBoxManage, or nothing at all for a bare hardware sandbox).
+type Backend interface {
+	// Prepare brings the guest to a ready-to-analyze state: typically
+	// rolling back to its latest snapshot and waiting for it to boot.
+	Prepare(ctx context.Context) error
+
+	// Cleanup shuts the guest back down after an analysis completes.
+	Cleanup(ctx context.Context) error
+
+	// HealthCheck reports whether the backend - and, where applicable, the
+	// guest it controls - is reachable and in a usable state.
+	HealthCheck(ctx context.Context) error
+
+	// Snapshot takes a new named snapshot of the guest's current state.
+	Snapshot(ctx context.Context, name string) error
+
+	// Rollback restores the guest to a previously taken named snapshot.
+	Rollback(ctx context.Context, name string) error
+}
@@ -0,0 +1,23 @@
+// Package external provides a no-op sandbox.Backend for hardware sandboxes
+// or any setup where the agent itself - or an operator - manages the
+// sandbox's lifecycle and cupax shouldn't try to snapshot, restore, or
+// power-cycle anything.
+package external
+
+import "context"
+
+// Backend does nothing for every lifecycle call; analysis proceeds
+// straight to the agent with no VM control step in front of it.
+type Backend struct{}
+
+// New creates a no-op Backend.
+func New() *Backend {
+	return &Backend{}
+}
+
+func (b *Backend) Prepare(ctx context.Context) error     { return nil }
+func (b *Backend) Cleanup(ctx context.Context) error     { return nil }
+func (b *Backend) HealthCheck(ctx context.Context) error { return nil }
+
+func (b *Backend) Snapshot(ctx context.Context, name string) error { return nil }
+func (b *Backend) Rollback(ctx context.Context, name string) error { return nil }
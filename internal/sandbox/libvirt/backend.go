@@ -0,0 +1,153 @@
+// Package libvirt drives a libvirt domain's lifecycle via libvirt-go, for
+// KVM/QEMU or Xen hosts managed outside Proxmox.
+package libvirt
+
+import (
+	"context"
+	"fmt"
+
+	libvirt "github.com/libvirt/libvirt-go"
+)
+
+// Config names the libvirt connection and domain this backend controls.
+type Config struct {
+	URI        string // libvirt connection URI, e.g. "qemu:///system"
+	DomainName string // libvirt domain (VM) name
+}
+
+// Backend drives a libvirt domain's lifecycle: rolling back to a snapshot
+// before analysis and shutting the domain down after. It connects lazily
+// on each call rather than holding a persistent libvirt.Connect open for
+// the whole process lifetime.
+type Backend struct {
+	cfg Config
+}
+
+// New creates a libvirt-backed sandbox.Backend.
+func New(cfg Config) *Backend {
+	return &Backend{cfg: cfg}
+}
+
+func (b *Backend) domain() (*libvirt.Connect, *libvirt.Domain, error) {
+	conn, err := libvirt.NewConnect(b.cfg.URI)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to libvirt at %s: %w", b.cfg.URI, err)
+	}
+
+	dom, err := conn.LookupDomainByName(b.cfg.DomainName)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to look up domain %s: %w", b.cfg.DomainName, err)
+	}
+
+	return conn, dom, nil
+}
+
+// Prepare reverts the domain to its current snapshot and starts it if it
+// isn't already running.
+func (b *Backend) Prepare(ctx context.Context) error {
+	conn, dom, err := b.domain()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer dom.Free()
+
+	snapshot, err := dom.SnapshotCurrent(0)
+	if err != nil {
+		return fmt.Errorf("failed to find current snapshot: %w", err)
+	}
+	defer snapshot.Free()
+
+	if err := dom.RevertToSnapshot(snapshot, 0); err != nil {
+		return fmt.Errorf("failed to revert to snapshot: %w", err)
+	}
+
+	active, err := dom.IsActive()
+	if err != nil {
+		return fmt.Errorf("failed to check domain state: %w", err)
+	}
+	if !active {
+		if err := dom.Create(); err != nil {
+			return fmt.Errorf("failed to start domain: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Cleanup gracefully shuts the domain down, destroying it outright if it
+// doesn't respond to the shutdown request.
+func (b *Backend) Cleanup(ctx context.Context) error {
+	conn, dom, err := b.domain()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer dom.Free()
+
+	if err := dom.Shutdown(); err != nil {
+		if derr := dom.Destroy(); derr != nil {
+			return fmt.Errorf("failed to shut down (%v) and failed to force destroy: %w", err, derr)
+		}
+	}
+	return nil
+}
+
+// HealthCheck reports whether the domain is currently active.
+func (b *Backend) HealthCheck(ctx context.Context) error {
+	conn, dom, err := b.domain()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer dom.Free()
+
+	active, err := dom.IsActive()
+	if err != nil {
+		return fmt.Errorf("failed to check domain state: %w", err)
+	}
+	if !active {
+		return fmt.Errorf("domain %s is not active", b.cfg.DomainName)
+	}
+	return nil
+}
+
+// Snapshot takes a new named external snapshot of the domain.
+func (b *Backend) Snapshot(ctx context.Context, name string) error {
+	conn, dom, err := b.domain()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer dom.Free()
+
+	xml := fmt.Sprintf("<domainsnapshot><name>%s</name></domainsnapshot>", name)
+	snapshot, err := dom.CreateSnapshotXML(xml, 0)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot %s: %w", name, err)
+	}
+	defer snapshot.Free()
+	return nil
+}
+
+// Rollback restores the domain to a previously taken named snapshot.
+func (b *Backend) Rollback(ctx context.Context, name string) error {
+	conn, dom, err := b.domain()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer dom.Free()
+
+	snapshot, err := dom.SnapshotLookupByName(name, 0)
+	if err != nil {
+		return fmt.Errorf("failed to find snapshot %s: %w", name, err)
+	}
+	defer snapshot.Free()
+
+	if err := dom.RevertToSnapshot(snapshot, 0); err != nil {
+		return fmt.Errorf("failed to revert to snapshot %s: %w", name, err)
+	}
+	return nil
+}
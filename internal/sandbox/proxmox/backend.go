@@ -0,0 +1,95 @@
+// Package proxmox adapts an internal/proxmox.Client to the sandbox.Backend
+// interface.
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cupax/cupax/internal/proxmox"
+)
+
+// Backend wires together the rollback/start and shutdown/stop sequences
+// the analyzer used to run inline against a *proxmox.Client.
+type Backend struct {
+	client *proxmox.Client
+}
+
+// New wraps an already-constructed Proxmox client as a sandbox.Backend.
+func New(client *proxmox.Client) *Backend {
+	return &Backend{client: client}
+}
+
+// Prepare rolls the guest back to its latest snapshot, waits for it to
+// reach the running state, and gives its agent time to start.
+func (b *Backend) Prepare(ctx context.Context) error {
+	log.Println("Proxmox: Preparing VM for analysis...")
+
+	log.Println("Proxmox: Restoring to latest snapshot...")
+	if err := b.client.RollbackToLatestAndWait(ctx, 2*time.Minute); err != nil {
+		return fmt.Errorf("failed to rollback to snapshot: %w", err)
+	}
+
+	log.Println("Proxmox: Waiting for VM to start after rollback...")
+	if err := b.client.WaitForStatus(ctx, "running", 2*time.Minute); err != nil {
+		return fmt.Errorf("VM did not reach running state: %w", err)
+	}
+
+	log.Println("Proxmox: Waiting for agent to be ready...")
+	time.Sleep(30 * time.Second)
+
+	log.Println("Proxmox: VM prepared successfully")
+	return nil
+}
+
+// Cleanup shuts the guest down gracefully, falling back to a forced stop
+// if it doesn't shut down in time.
+func (b *Backend) Cleanup(ctx context.Context) error {
+	log.Println("Proxmox: Shutting down VM after analysis...")
+
+	if err := b.client.ShutdownAndWait(ctx, 2*time.Minute); err != nil {
+		log.Printf("Proxmox: Graceful shutdown failed: %v", err)
+		log.Println("Proxmox: Forcing VM stop...")
+
+		ref, err := b.client.StopVM(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to stop VM: %w", err)
+		}
+		if err := b.client.WaitForTask(ctx, ref, 2*time.Minute); err != nil {
+			return fmt.Errorf("failed to stop VM: %w", err)
+		}
+	}
+
+	log.Println("Proxmox: VM shutdown successfully")
+	return nil
+}
+
+// HealthCheck reports whether the guest is currently running.
+func (b *Backend) HealthCheck(ctx context.Context) error {
+	status, err := b.client.GetVMStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to query VM status: %w", err)
+	}
+	if status != "running" {
+		return fmt.Errorf("VM is not running (status: %s)", status)
+	}
+	return nil
+}
+
+// Snapshot takes a new named snapshot of the guest's current state.
+func (b *Backend) Snapshot(ctx context.Context, name string) error {
+	_, err := b.client.CreateSnapshot(ctx, name, "", false)
+	return err
+}
+
+// Rollback restores the guest to a previously taken named snapshot and
+// waits for the restore task to finish.
+func (b *Backend) Rollback(ctx context.Context, name string) error {
+	ref, err := b.client.RollbackToSnapshot(ctx, name)
+	if err != nil {
+		return err
+	}
+	return b.client.WaitForTask(ctx, ref, 2*time.Minute)
+}
@@ -0,0 +1,86 @@
+// Package virtualbox drives a VirtualBox VM's lifecycle by shelling out to
+// VBoxManage, for desktop/workstation-hosted sandboxes.
+package virtualbox
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Config names the VM and VBoxManage binary this backend controls.
+type Config struct {
+	VMName         string // VBoxManage VM name or UUID
+	VBoxManagePath string // path to the VBoxManage binary; "" uses $PATH
+}
+
+// Backend snapshots/restores and starts/stops a VirtualBox VM around each
+// analysis via the VBoxManage CLI.
+type Backend struct {
+	cfg Config
+}
+
+// New creates a VirtualBox-backed sandbox.Backend.
+func New(cfg Config) *Backend {
+	return &Backend{cfg: cfg}
+}
+
+func (b *Backend) vboxManage() string {
+	if b.cfg.VBoxManagePath != "" {
+		return b.cfg.VBoxManagePath
+	}
+	return "VBoxManage"
+}
+
+func (b *Backend) run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, b.vboxManage(), args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w: %s", b.vboxManage(), strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// Prepare restores the VM to its current snapshot and starts it headless.
+func (b *Backend) Prepare(ctx context.Context) error {
+	if _, err := b.run(ctx, "snapshot", b.cfg.VMName, "restorecurrent"); err != nil {
+		return fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+	if _, err := b.run(ctx, "startvm", b.cfg.VMName, "--type", "headless"); err != nil {
+		return fmt.Errorf("failed to start VM: %w", err)
+	}
+	return nil
+}
+
+// Cleanup powers the VM off.
+func (b *Backend) Cleanup(ctx context.Context) error {
+	if _, err := b.run(ctx, "controlvm", b.cfg.VMName, "poweroff"); err != nil {
+		return fmt.Errorf("failed to power off VM: %w", err)
+	}
+	return nil
+}
+
+// HealthCheck reports whether VBoxManage considers the VM running.
+func (b *Backend) HealthCheck(ctx context.Context) error {
+	out, err := b.run(ctx, "showvminfo", b.cfg.VMName, "--machinereadable")
+	if err != nil {
+		return fmt.Errorf("failed to query VM state: %w", err)
+	}
+	if !strings.Contains(out, `VMState="running"`) {
+		return fmt.Errorf("VM %s is not running", b.cfg.VMName)
+	}
+	return nil
+}
+
+// Snapshot takes a new named snapshot of the VM's current state.
+func (b *Backend) Snapshot(ctx context.Context, name string) error {
+	_, err := b.run(ctx, "snapshot", b.cfg.VMName, "take", name)
+	return err
+}
+
+// Rollback restores the VM to a previously taken named snapshot.
+func (b *Backend) Rollback(ctx context.Context, name string) error {
+	_, err := b.run(ctx, "snapshot", b.cfg.VMName, "restore", name)
+	return err
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FSStore is the original local-disk SampleStore: samples live under
+// baseDir named by hash+ext, exactly as HandleUploadFile wrote them before
+// SampleStore existed.
+type FSStore struct {
+	baseDir string
+}
+
+// NewFSStore creates an FSStore rooted at baseDir. baseDir is created on
+// first Put if it doesn't already exist.
+func NewFSStore(baseDir string) *FSStore {
+	return &FSStore{baseDir: baseDir}
+}
+
+const fsURIPrefix = "file://"
+
+func (s *FSStore) Put(hash, ext string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(s.baseDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create samples directory: %w", err)
+	}
+
+	path := filepath.Join(s.baseDir, hash+ext)
+	dst, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to save sample: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, r); err != nil {
+		return "", fmt.Errorf("failed to write sample: %w", err)
+	}
+
+	return fsURIPrefix + path, nil
+}
+
+func (s *FSStore) Get(uri string) (io.ReadCloser, error) {
+	path, err := s.pathFromURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sample: %w", err)
+	}
+	return f, nil
+}
+
+func (s *FSStore) Delete(uri string) error {
+	path, err := s.pathFromURI(uri)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete sample: %w", err)
+	}
+	return nil
+}
+
+// PresignedGet always fails: a local path isn't reachable by a remote
+// agent, so sendToAgent falls back to proxying the bytes itself.
+func (s *FSStore) PresignedGet(uri string, ttl time.Duration) (string, error) {
+	return "", fmt.Errorf("presigned URLs are not supported by the filesystem storage backend")
+}
+
+func (s *FSStore) pathFromURI(uri string) (string, error) {
+	if !strings.HasPrefix(uri, fsURIPrefix) {
+		return "", fmt.Errorf("invalid fs sample URI: %q", uri)
+	}
+	return strings.TrimPrefix(uri, fsURIPrefix), nil
+}
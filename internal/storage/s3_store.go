@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Store stores samples in an S3-compatible bucket via MinIO's client,
+// so the agent can be handed a PresignedGet URL instead of having bytes
+// proxied through the API server.
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+// S3Config holds the connection details NewS3Store needs. It carries the
+// same connection fields as config.StorageConfig (all but Backend), which
+// main.go copies across, so this package does not import internal/config.
+type S3Config struct {
+	Endpoint  string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	Region    string
+}
+
+// NewS3Store connects to the configured endpoint and ensures the target
+// bucket exists, creating it if this is the first run against it.
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create minio client: %w", err)
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket %q: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket %q: %w", cfg.Bucket, err)
+		}
+	}
+
+	return &S3Store{client: client, bucket: cfg.Bucket}, nil
+}
+
+func s3URIPrefix(bucket string) string {
+	return "s3://" + bucket + "/"
+}
+
+func (s *S3Store) Put(hash, ext string, r io.Reader) (string, error) {
+	key := hash + ext
+	if _, err := s.client.PutObject(context.Background(), s.bucket, key, r, -1, minio.PutObjectOptions{}); err != nil {
+		return "", fmt.Errorf("failed to upload sample %q: %w", key, err)
+	}
+	return s3URIPrefix(s.bucket) + key, nil
+}
+
+func (s *S3Store) Get(uri string) (io.ReadCloser, error) {
+	key, err := s.keyFromURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := s.client.GetObject(context.Background(), s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sample %q: %w", key, err)
+	}
+	return obj, nil
+}
+
+func (s *S3Store) Delete(uri string) error {
+	key, err := s.keyFromURI(uri)
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.RemoveObject(context.Background(), s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete sample %q: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3Store) PresignedGet(uri string, ttl time.Duration) (string, error) {
+	key, err := s.keyFromURI(uri)
+	if err != nil {
+		return "", err
+	}
+
+	presigned, err := s.client.PresignedGetObject(context.Background(), s.bucket, key, ttl, url.Values{})
+	if err != nil {
+		return "", fmt.Errorf("failed to presign sample %q: %w", key, err)
+	}
+	return presigned.String(), nil
+}
+
+func (s *S3Store) keyFromURI(uri string) (string, error) {
+	prefix := s3URIPrefix(s.bucket)
+	if !strings.HasPrefix(uri, prefix) {
+		return "", fmt.Errorf("invalid s3 sample URI for bucket %q: %q", s.bucket, uri)
+	}
+	return strings.TrimPrefix(uri, prefix), nil
+}
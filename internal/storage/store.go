@@ -0,0 +1,30 @@
+// Package storage abstracts where uploaded malware samples are persisted,
+// so the API server can run with local disk or an S3/MinIO bucket behind
+// the same interface.
+package storage
+
+import (
+	"io"
+	"time"
+)
+
+// SampleStore stores and retrieves uploaded samples by an opaque URI it
+// assigns on Put. Implementations are free to choose their own URI scheme
+// (FSStore uses file://, S3Store uses s3://bucket/key).
+type SampleStore interface {
+	// Put writes r under a key derived from hash and ext and returns a URI
+	// identifying it for later Get/Delete/PresignedGet calls.
+	Put(hash, ext string, r io.Reader) (uri string, err error)
+
+	// Get opens the sample previously stored at uri. Callers must Close it.
+	Get(uri string) (io.ReadCloser, error)
+
+	// Delete removes the sample stored at uri.
+	Delete(uri string) error
+
+	// PresignedGet returns a time-limited URL a remote agent can fetch uri
+	// from directly, instead of the API server proxying the bytes itself.
+	// Backends that can't produce one, such as FSStore, return an error;
+	// callers should fall back to Get and proxy the bytes themselves.
+	PresignedGet(uri string, ttl time.Duration) (string, error)
+}
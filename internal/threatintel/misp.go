@@ -0,0 +1,80 @@
+package threatintel
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cupax/cupax/internal/models"
+)
+
+// mispFeed is the subset of a MISP feed export we care about. MISP feeds
+// are published either as a single event or as a feed manifest of events;
+// this importer accepts a single event document.
+type mispFeed struct {
+	Event struct {
+		Info       string          `json:"info"`
+		Attributes []mispAttribute `json:"Attribute"`
+	} `json:"Event"`
+}
+
+type mispAttribute struct {
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Comment string `json:"comment"`
+}
+
+// MISPImporter maps MISP feed attributes into whitelist entries
+type MISPImporter struct{}
+
+// Import parses a MISP event document and returns one whitelist entry per
+// attribute whose type this importer recognizes
+func (m *MISPImporter) Import(data []byte) ([]models.Whitelist, error) {
+	var feed mispFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse MISP feed: %w", err)
+	}
+
+	var entries []models.Whitelist
+	for _, attr := range feed.Event.Attributes {
+		wlType, ok := mispAttributeType(attr.Type)
+		if !ok {
+			continue
+		}
+
+		description := attr.Comment
+		if description == "" {
+			description = feed.Event.Info
+		}
+		if description == "" {
+			description = "Imported from MISP feed"
+		}
+
+		entries = append(entries, models.Whitelist{
+			Type:        wlType,
+			Value:       attr.Value,
+			Description: description,
+			IsRegex:     false,
+			Enabled:     true,
+		})
+	}
+
+	return entries, nil
+}
+
+// mispAttributeType maps a MISP attribute type to a WhitelistType, matching
+// the attribute types this importer understands: ip-src/ip-dst, domain/
+// hostname, sha256 file hashes and regkey Windows registry keys
+func mispAttributeType(attrType string) (models.WhitelistType, bool) {
+	switch attrType {
+	case "ip-src", "ip-dst":
+		return models.WhitelistTypeIP, true
+	case "domain", "hostname":
+		return models.WhitelistTypeDomain, true
+	case "sha256":
+		return models.WhitelistTypeHash, true
+	case "regkey":
+		return models.WhitelistTypeRegistry, true
+	default:
+		return "", false
+	}
+}
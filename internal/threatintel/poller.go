@@ -0,0 +1,124 @@
+package threatintel
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/cupax/cupax/internal/database"
+	"github.com/cupax/cupax/internal/models"
+)
+
+// Poller periodically refreshes enabled threat-intel feeds and syncs the
+// whitelist entries they produce
+type Poller struct {
+	repo       *database.Repository
+	httpClient *http.Client
+	interval   time.Duration
+}
+
+// NewPoller creates a new feed poller. interval controls how often Run
+// refreshes feeds; a single pass can always be triggered with PollOnce.
+func NewPoller(repo *database.Repository, interval time.Duration) *Poller {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &Poller{
+		repo:       repo,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		interval:   interval,
+	}
+}
+
+// Run blocks, refreshing every enabled feed on each tick of the poller's
+// interval, until stopCh is closed
+func (p *Poller) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.PollOnce()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.PollOnce()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// PollOnce refreshes every enabled feed a single time, logging failures
+// per-feed so one bad feed doesn't block the rest
+func (p *Poller) PollOnce() {
+	feeds, err := p.repo.GetEnabledThreatFeeds()
+	if err != nil {
+		log.Printf("threatintel: failed to list enabled feeds: %v", err)
+		return
+	}
+
+	for _, feed := range feeds {
+		if err := p.syncFeed(feed); err != nil {
+			log.Printf("threatintel: failed to sync feed %s (%s): %v", feed.Name, feed.ID, err)
+		}
+	}
+}
+
+// syncFeed fetches a single feed with conditional-GET caching and, if the
+// feed changed, imports and syncs its entries
+func (p *Poller) syncFeed(feed models.ThreatFeed) error {
+	req, err := http.NewRequest(http.MethodGet, feed.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	if feed.ETag != "" {
+		req.Header.Set("If-None-Match", feed.ETag)
+	}
+	if feed.LastModified != "" {
+		req.Header.Set("If-Modified-Since", feed.LastModified)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		log.Printf("threatintel: feed %s not modified, skipping", feed.Name)
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("feed returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read feed body: %w", err)
+	}
+
+	importer, err := NewImporter(feed.Kind)
+	if err != nil {
+		return err
+	}
+
+	entries, err := importer.Import(body)
+	if err != nil {
+		return fmt.Errorf("failed to import feed: %w", err)
+	}
+
+	added, err := p.repo.SyncFromFeed(feed.ID, entries)
+	if err != nil {
+		return fmt.Errorf("failed to sync feed entries: %w", err)
+	}
+
+	if err := p.repo.UpdateThreatFeedCache(feed.ID, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), time.Now()); err != nil {
+		return fmt.Errorf("failed to update feed cache: %w", err)
+	}
+
+	log.Printf("threatintel: synced %d entries from feed %s", added, feed.Name)
+	return nil
+}
@@ -0,0 +1,94 @@
+package threatintel
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/cupax/cupax/internal/models"
+)
+
+// stixBundle is the subset of a STIX 2.1 bundle we care about
+type stixBundle struct {
+	Type    string          `json:"type"`
+	Objects []stixIndicator `json:"objects"`
+}
+
+type stixIndicator struct {
+	Type        string `json:"type"`
+	Pattern     string `json:"pattern"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// stixPattern matches a single comparison expression inside a STIX pattern,
+// e.g. "[domain-name:value = 'evil.example.com']" or
+// "[file:hashes.'SHA-256' = 'abcd...']". Only equality comparisons on the
+// object paths we support are extracted; anything else is ignored.
+var stixPattern = regexp.MustCompile(`(ipv4-addr|ipv6-addr|domain-name|file|windows-registry-key):([\w.'-]+)\s*=\s*'([^']+)'`)
+
+// STIXImporter maps STIX 2.1 "indicator" objects into whitelist entries
+type STIXImporter struct{}
+
+// Import parses a STIX 2.1 bundle and returns one whitelist entry per
+// indicator/comparison pair it recognizes
+func (s *STIXImporter) Import(data []byte) ([]models.Whitelist, error) {
+	var bundle stixBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse STIX bundle: %w", err)
+	}
+
+	var entries []models.Whitelist
+	for _, obj := range bundle.Objects {
+		if obj.Type != "indicator" {
+			continue
+		}
+
+		matches := stixPattern.FindAllStringSubmatch(obj.Pattern, -1)
+		for _, m := range matches {
+			wlType, ok := stixObjectType(m[1], m[2])
+			if !ok {
+				continue
+			}
+
+			entries = append(entries, models.Whitelist{
+				Type:        wlType,
+				Value:       m[3],
+				Description: stixDescription(obj),
+				IsRegex:     false,
+				Enabled:     true,
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+// stixObjectType maps a STIX object:path pair to a WhitelistType, matching
+// the indicator types this importer understands: ipv4-addr, ipv6-addr,
+// domain-name, file:hashes.SHA-256 and windows-registry-key
+func stixObjectType(object, path string) (models.WhitelistType, bool) {
+	switch object {
+	case "ipv4-addr", "ipv6-addr":
+		return models.WhitelistTypeIP, true
+	case "domain-name":
+		return models.WhitelistTypeDomain, true
+	case "windows-registry-key":
+		return models.WhitelistTypeRegistry, true
+	case "file":
+		if path == "hashes.'SHA-256'" || path == "hashes.SHA-256" {
+			return models.WhitelistTypeHash, true
+		}
+	}
+	return "", false
+}
+
+func stixDescription(obj stixIndicator) string {
+	if obj.Name != "" {
+		return obj.Name
+	}
+	if obj.Description != "" {
+		return obj.Description
+	}
+	return "Imported from STIX feed"
+}
@@ -0,0 +1,30 @@
+// Package threatintel maps external STIX 2.1 and MISP feed documents into
+// cupax whitelist entries, so analysts can subscribe to community
+// allowlists (Alexa top domains, Microsoft signed binaries, ...) instead of
+// hand-curating every entry.
+package threatintel
+
+import (
+	"fmt"
+
+	"github.com/cupax/cupax/internal/models"
+)
+
+// Importer parses a feed document into whitelist entries. Implementations
+// must not set Whitelist.Source or Whitelist.ID; the caller (Poller or
+// repository) stamps those before persisting.
+type Importer interface {
+	Import(data []byte) ([]models.Whitelist, error)
+}
+
+// NewImporter returns the Importer for a feed kind
+func NewImporter(kind models.ThreatFeedKind) (Importer, error) {
+	switch kind {
+	case models.ThreatFeedKindSTIX:
+		return &STIXImporter{}, nil
+	case models.ThreatFeedKindMISP:
+		return &MISPImporter{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported threat feed kind: %s", kind)
+	}
+}
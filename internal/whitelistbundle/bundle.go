@@ -0,0 +1,218 @@
+// Package whitelistbundle implements the signed YAML export/import format
+// for whitelist entries, so a curated pack can be checked into git, signed
+// offline with an ed25519 key, and rolled out across a fleet of cupax
+// instances that trust that key.
+package whitelistbundle
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/cupax/cupax/internal/models"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// BundleVersion is the current bundle format version, stamped into every
+// export so a future format change can detect and migrate older bundles.
+const BundleVersion = 1
+
+// ErrUnsigned is returned by Verify when a bundle carries no signature at
+// all, distinct from a signature that's present but invalid - callers
+// (HandleImportWhitelists) only waive this one when AllowUnsigned is set.
+var ErrUnsigned = errors.New("bundle has no signature")
+
+// typeOrder fixes the section order entries are grouped and marshaled in,
+// so two exports of the same whitelists lay out their sections in the same
+// order and Canonical is stable regardless of map iteration order.
+var typeOrder = []models.WhitelistType{
+	models.WhitelistTypeProcess,
+	models.WhitelistTypeDomain,
+	models.WhitelistTypeIP,
+	models.WhitelistTypeRegistry,
+	models.WhitelistTypeHash,
+}
+
+// Entry is one whitelist row within a TypeSection. Type and Source aren't
+// repeated per-entry: Type is implied by the enclosing section, and Source
+// (feed provenance) isn't part of the hand-curated export format.
+type Entry struct {
+	ID          uuid.UUID `yaml:"id"`
+	Value       string    `yaml:"value"`
+	Description string    `yaml:"description,omitempty"`
+	IsRegex     bool      `yaml:"is_regex,omitempty"`
+	IsCIDR      bool      `yaml:"is_cidr,omitempty"`
+	Enabled     bool      `yaml:"enabled"`
+}
+
+// TypeSection groups every entry of one whitelist type together.
+type TypeSection struct {
+	Type    models.WhitelistType `yaml:"type"`
+	Entries []Entry              `yaml:"entries"`
+}
+
+// Signature is a detached ed25519 signature over the bundle's Canonical
+// form. KeyID identifies which public key in the verifier's trust store
+// (Config.Whitelists.TrustedKeys) to check it against.
+type Signature struct {
+	KeyID     string `yaml:"key_id"`
+	Algorithm string `yaml:"algorithm"`
+	Value     string `yaml:"value"` // hex-encoded
+}
+
+// Bundle is the YAML-serializable whitelist export/import format.
+type Bundle struct {
+	Version        int           `yaml:"version"`
+	GeneratedAt    time.Time     `yaml:"generated_at"`
+	SourceInstance string        `yaml:"source_instance"`
+	Whitelists     []TypeSection `yaml:"whitelists"`
+	Signature      *Signature    `yaml:"signature,omitempty"`
+}
+
+// FromWhitelists groups entries into a Bundle ready to marshal, stamped
+// with generatedAt/sourceInstance.
+func FromWhitelists(entries []models.Whitelist, sourceInstance string, generatedAt time.Time) *Bundle {
+	byType := make(map[models.WhitelistType][]Entry)
+	for _, wl := range entries {
+		byType[wl.Type] = append(byType[wl.Type], Entry{
+			ID:          wl.ID,
+			Value:       wl.Value,
+			Description: wl.Description,
+			IsRegex:     wl.IsRegex,
+			IsCIDR:      wl.IsCIDR,
+			Enabled:     wl.Enabled,
+		})
+	}
+
+	bundle := &Bundle{
+		Version:        BundleVersion,
+		GeneratedAt:    generatedAt,
+		SourceInstance: sourceInstance,
+	}
+	for _, t := range typeOrder {
+		if section, ok := byType[t]; ok {
+			bundle.Whitelists = append(bundle.Whitelists, TypeSection{Type: t, Entries: section})
+		}
+	}
+	return bundle
+}
+
+// ToWhitelists flattens the bundle back into []models.Whitelist, assigning
+// a fresh ID to any entry that didn't carry one. Call Validate first.
+func (b *Bundle) ToWhitelists() []models.Whitelist {
+	var out []models.Whitelist
+	now := time.Now()
+
+	for _, section := range b.Whitelists {
+		for _, e := range section.Entries {
+			id := e.ID
+			if id == uuid.Nil {
+				id = uuid.New()
+			}
+			out = append(out, models.Whitelist{
+				ID:          id,
+				Type:        section.Type,
+				Value:       e.Value,
+				Description: e.Description,
+				IsRegex:     e.IsRegex,
+				IsCIDR:      e.IsCIDR,
+				Enabled:     e.Enabled,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			})
+		}
+	}
+
+	return out
+}
+
+// Validate checks structural invariants before anything is applied to the
+// database: known type, non-empty value, compilable regex for IsRegex
+// entries, and no duplicate (type, value) pair within the bundle itself.
+func (b *Bundle) Validate() error {
+	seen := make(map[string]struct{})
+
+	for _, section := range b.Whitelists {
+		if !validType(section.Type) {
+			return fmt.Errorf("unknown whitelist type %q", section.Type)
+		}
+
+		for _, e := range section.Entries {
+			if e.Value == "" {
+				return fmt.Errorf("%s entry has an empty value", section.Type)
+			}
+			if e.IsRegex {
+				if _, err := regexp.Compile(e.Value); err != nil {
+					return fmt.Errorf("%s entry %q is not a valid regex: %w", section.Type, e.Value, err)
+				}
+			}
+
+			key := string(section.Type) + "\x00" + e.Value
+			if _, dup := seen[key]; dup {
+				return fmt.Errorf("duplicate entry (%s, %q) in bundle", section.Type, e.Value)
+			}
+			seen[key] = struct{}{}
+		}
+	}
+
+	return nil
+}
+
+func validType(t models.WhitelistType) bool {
+	switch t {
+	case models.WhitelistTypeProcess, models.WhitelistTypeDomain, models.WhitelistTypeIP,
+		models.WhitelistTypeRegistry, models.WhitelistTypeHash:
+		return true
+	default:
+		return false
+	}
+}
+
+// Canonical returns the deterministic byte serialization that's signed and
+// verified: the bundle's YAML encoding with any existing signature
+// stripped, so signing is reproducible and verification doesn't depend on
+// what the signature field happened to contain.
+func (b *Bundle) Canonical() ([]byte, error) {
+	unsigned := *b
+	unsigned.Signature = nil
+	return yaml.Marshal(unsigned)
+}
+
+// Verify reports whether the bundle carries a valid ed25519 signature from
+// one of trustedKeys, keyed by key ID. Returns ErrUnsigned specifically
+// when there's no signature at all, so callers can choose to let that case
+// through under Config.Whitelists.AllowUnsigned while still rejecting a
+// present-but-invalid signature outright.
+func (b *Bundle) Verify(trustedKeys map[string]ed25519.PublicKey) error {
+	if b.Signature == nil {
+		return ErrUnsigned
+	}
+	if b.Signature.Algorithm != "ed25519" {
+		return fmt.Errorf("unsupported signature algorithm %q", b.Signature.Algorithm)
+	}
+
+	pub, ok := trustedKeys[b.Signature.KeyID]
+	if !ok {
+		return fmt.Errorf("signature key %q is not in the trust store", b.Signature.KeyID)
+	}
+
+	sig, err := hex.DecodeString(b.Signature.Value)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	canon, err := b.Canonical()
+	if err != nil {
+		return fmt.Errorf("failed to compute canonical form: %w", err)
+	}
+
+	if !ed25519.Verify(pub, canon, sig) {
+		return fmt.Errorf("signature verification failed")
+	}
+
+	return nil
+}